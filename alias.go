@@ -0,0 +1,11 @@
+package signer
+
+import "unsafe"
+
+// anyOverlap reports whether x and y share memory at any index. It follows
+// golang.org/x/crypto/internal/subtle, which can not be imported.
+func anyOverlap(x, y []byte) bool {
+	return len(x) > 0 && len(y) > 0 &&
+		uintptr(unsafe.Pointer(&x[0])) <= uintptr(unsafe.Pointer(&y[len(y)-1])) &&
+		uintptr(unsafe.Pointer(&y[0])) <= uintptr(unsafe.Pointer(&x[len(x)-1]))
+}
@@ -0,0 +1,78 @@
+package signer
+
+import (
+	"io"
+	"sync"
+)
+
+// SignBatch signs each msg with a random nonce, returning the tokens in the
+// same order as msgs. The nonces for the whole batch are drawn with a
+// single read from the randomness source, rather than one read per msg,
+// unless the source is a CounterNonce, which yields one nonce per read.
+func (s *Signer) SignBatch(msgs [][]byte) ([]Token, error) {
+	if s.isClosed() {
+		return nil, ErrClosed
+	}
+	for _, msg := range msgs {
+		if len(msg) > s.maxMsgSize() {
+			return nil, ErrTooLong
+		}
+	}
+	ns := s.NonceSize()
+	nonces := make([]byte, len(msgs)*ns)
+	step := len(nonces)
+	if _, ok := s.Rand.(*CounterNonce); ok {
+		step = ns
+	}
+	for i := 0; i < len(nonces); i += step {
+		if _, err := io.ReadFull(s.rand(), nonces[i:i+step]); err != nil {
+			return nil, err
+		}
+	}
+	if s.StrictNonce && s.Guard != nil {
+		for i := 0; i < len(nonces); i += ns {
+			if err := s.Guard.Use(nonces[i : i+ns]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	t := make([]Token, len(msgs))
+	for i, msg := range msgs {
+		var err error
+		if t[i], err = s.sign(msg, nil, nonces[i*ns:(i+1)*ns], ext{}); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// VerifyBatch verifies each token with up to workers goroutines, returning
+// the msgs and errors in the same order as tokens: msgs[i] and errs[i] are
+// the results of s.Verify(tokens[i]). If workers < 1, one is used.
+func (s *Signer) VerifyBatch(tokens []Token, workers int) (msgs [][]byte, errs []error) {
+	msgs = make([][]byte, len(tokens))
+	errs = make([]error, len(tokens))
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(tokens) {
+		workers = len(tokens)
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				msgs[i], errs[i] = s.Verify(tokens[i])
+			}
+		}()
+	}
+	for i := range tokens {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return msgs, errs
+}
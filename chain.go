@@ -0,0 +1,117 @@
+package signer
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+// chainAAD prefixes the previous tag in the associated data of a chain
+// entry, so that it can not collide with aad passed to SignWithAAD
+const chainAAD = "signer chain\x00"
+
+// NewChain returns an empty Chain signing entries with s
+func NewChain(s *Signer) *Chain {
+	return &Chain{s: s}
+}
+
+// Chain authenticates a sequence of entries, such as an append-only log,
+// so that removing, reordering, or modifying entries is detectable. Each
+// entry is a token labeled with its position in the chain (see Label), and
+// sealed with the tag of the previous entry as additional data.
+//
+// A Chain remembers the length and last tag of the chain it built, its
+// head, so it also detects entries removed from the end. It is safe for
+// concurrent use by multiple goroutines.
+type Chain struct {
+	s    *Signer
+	mu   sync.Mutex
+	n    uint64
+	prev []byte // tag of the last entry
+}
+
+// Append signs entry as the next token in the chain
+func (c *Chain) Append(entry []byte) (Token, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, err := c.s.SignOpts(entry, SignOptions{Label: chainLabel(c.n), AAD: append([]byte(chainAAD), c.prev...)})
+	if err != nil {
+		return nil, err
+	}
+	c.n++
+	// a new slice, as VerifyChain may still be reading the old one
+	c.prev = append([]byte(nil), t[len(t)-c.s.Overhead():]...)
+	return t, nil
+}
+
+// ChainHead is the length of a chain and the tag of its last entry, as
+// kept by a Chain. Storing it with the entries lets a later process check
+// that none were removed from the end, and resume appending with
+// ResumeChain. The head is not secret, but must be stored where it can not
+// be rolled back along with the entries.
+type ChainHead struct {
+	N    uint64
+	Last []byte
+}
+
+// ResumeChain returns a Chain that signs entries with s, continuing the
+// chain whose head is h
+func ResumeChain(s *Signer, h ChainHead) *Chain {
+	return &Chain{s: s, n: h.N, prev: append([]byte(nil), h.Last...)}
+}
+
+// Head returns the head of the chain built by c
+func (c *Chain) Head() ChainHead {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ChainHead{N: c.n, Last: append([]byte(nil), c.prev...)}
+}
+
+// VerifyChain verifies that entries is the whole chain built by c, in
+// order. It is the package's VerifyChain, checked against c's head.
+func (c *Chain) VerifyChain(entries []Token) error {
+	h := c.Head()
+	return VerifyChain(c.s, entries, &h)
+}
+
+// VerifyChain verifies that entries is a chain signed by s, in order, from
+// its first entry, without the Chain that built it. It returns
+// ErrChainOrder if an entry is missing or out of place, and an error
+// matching ErrUnverified if one was modified.
+//
+// Entries removed from the end of a chain are only detected against its
+// head: if head is not nil, VerifyChain returns ErrTruncated for fewer
+// entries than head.N, and ErrChainOrder for more, or for a last entry
+// other than head's.
+func VerifyChain(s *Signer, entries []Token, head *ChainHead) error {
+	var prev []byte
+	for i, t := range entries {
+		label, err := Label(t)
+		if err != nil {
+			return err
+		}
+		if string(label) != string(chainLabel(uint64(i))) {
+			return ErrChainOrder
+		}
+		if _, err := s.VerifyWithAAD(t, append([]byte(chainAAD), prev...)); err != nil {
+			return err
+		}
+		prev = t[len(t)-s.Overhead():]
+	}
+	if head == nil {
+		return nil
+	}
+	if uint64(len(entries)) < head.N {
+		return ErrTruncated
+	}
+	if uint64(len(entries)) > head.N || string(prev) != string(head.Last) {
+		return ErrChainOrder
+	}
+	return nil
+}
+
+// chainLabel returns the label of the chain entry at position i
+func chainLabel(i uint64) []byte {
+	p := make([]byte, 8)
+	binary.BigEndian.PutUint64(p, i)
+	return p
+}
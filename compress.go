@@ -0,0 +1,51 @@
+package signer
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+)
+
+// SignCompressed is like Sign, but compresses msg with DEFLATE before
+// sealing it. The header records that the msg is compressed, and Verify
+// decompresses it transparently.
+//
+// Compression makes the token's length depend on the msg's contents. If
+// an attacker controls part of the msg, they may learn about the rest of
+// it from the token's length, so avoid compressing msgs that mix secrets
+// with attacker-controlled data.
+func (s *Signer) SignCompressed(msg, nonce []byte) (t Token, err error) {
+	return s.SignOpts(msg, SignOptions{Nonce: nonce, Compress: true})
+}
+
+func compress(msg []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w, err := flate.NewWriter(&b, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// inflate decompresses p and appends it to dst. To guard against
+// decompression bombs, it returns ErrTooLong once the output exceeds
+// the Signer's MaxMsgSize.
+func (s *Signer) inflate(dst, p []byte) ([]byte, error) {
+	max := s.maxMsgSize()
+	b := bytes.NewBuffer(dst)
+	r := flate.NewReader(bytes.NewReader(p))
+	n, err := io.Copy(b, io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > int64(max) {
+		return nil, ErrTooLong
+	}
+	return b.Bytes(), nil
+}
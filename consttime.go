@@ -0,0 +1,58 @@
+package signer
+
+// VerifyConstantTime is like Verify, but reports only whether t verified,
+// for gates where the kind of failure, or how quickly it is found, must not
+// be revealed. Short tokens, tokens with a wrong version or key id, and
+// tampered tokens all return nil and false.
+//
+// A token that can not be opened because of its header is still run
+// through the AEAD, with a zero nonce, so that a malformed token costs
+// about as much as a tampered one of the same length. The guarantee is
+// limited to what the AEAD offers: its running time depends on the length
+// of the token, which the caller already knows, and parsing the header
+// takes time dependent on its contents, though small next to the AEAD. A
+// token that verifies and is compressed takes longer still, to decompress.
+// For a Signer from NewRotating, a token that does not verify with the
+// current key takes about twice as long, to try the previous one.
+// OnVerifyFail is not called, as it would take time of its own.
+func (s *Signer) VerifyConstantTime(t Token) (msg []byte, ok bool) {
+	h, err := s.parse(t)
+	good := 1
+	if err != nil || s.isClosed() {
+		good = 0
+	}
+	var zeros [NonceSize]byte
+	nonce, hdr, ae := zeros[:s.NonceSize()], t[:0], []byte(t)
+	if good == 1 {
+		nonce, hdr, ae = h.nonce, t[:h.n], t[h.n:]
+	}
+	if len(ae) < s.Overhead() {
+		// Open would return without any work for a token this short
+		good = 0
+		ae = make([]byte, s.Overhead())
+	}
+	if max := s.maxMsgSize() + s.Overhead(); len(ae) > max {
+		good = 0
+		ae = ae[:max]
+	}
+	var p []byte
+	if s.tagLen != 0 {
+		p, err = s.openTruncated(nil, nonce, ae, hdr)
+	} else {
+		p, err = openAEAD(s.aead, nil, nonce, ae, hdr)
+		if err != nil && s.prev != nil {
+			p, err = openAEAD(s.prev, nil, nonce, ae, hdr)
+		}
+	}
+	opened := 0
+	if err == nil {
+		opened = 1
+	}
+	if good&opened == 0 {
+		return nil, false
+	}
+	if msg, err = s.decoded(nil, h, p); err != nil {
+		return nil, false
+	}
+	return msg, true
+}
@@ -0,0 +1,60 @@
+package signer
+
+import (
+	"net/http"
+	"time"
+)
+
+// CookieOptions are the optional attributes of a signed cookie. Signed
+// cookies are always Secure and HttpOnly.
+type CookieOptions struct {
+	Path     string
+	Domain   string
+	Expires  time.Time
+	MaxAge   int
+	SameSite http.SameSite
+}
+
+// SetSignedCookie signs msg and sets it as the value of the named cookie.
+// The cookie name is authenticated with the msg, so the value can not be
+// moved to a cookie with a different name, nor verified as any other kind
+// of token.
+func (s *Signer) SetSignedCookie(w http.ResponseWriter, name string, msg []byte, opts CookieOptions) error {
+	t, err := s.SignWithAAD(msg, cookieAAD(name), nil)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    t.Encode(),
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		Expires:  opts.Expires,
+		MaxAge:   opts.MaxAge,
+		SameSite: opts.SameSite,
+		Secure:   true,
+		HttpOnly: true,
+	})
+	return nil
+}
+
+// ReadSignedCookie verifies the named cookie set by SetSignedCookie and
+// returns its msg. If the request has no such cookie, it returns
+// ErrNoCookie.
+func (s *Signer) ReadSignedCookie(r *http.Request, name string) ([]byte, error) {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return nil, ErrNoCookie
+	}
+	t, err := ParseToken(c.Value)
+	if err != nil {
+		return nil, err
+	}
+	return s.VerifyWithAAD(t, cookieAAD(name))
+}
+
+// cookieAAD returns the associated data binding a token to a cookie name.
+// It is prefixed so that it can not collide with aad passed to SignWithAAD.
+func cookieAAD(name string) []byte {
+	return append([]byte("signer cookie\x00"), name...)
+}
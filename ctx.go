@@ -0,0 +1,55 @@
+package signer
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+// SignCtx is like SignWithAAD, with the associated data taken from ctx by
+// s.ContextAAD, binding the token to ambient request values without passing
+// them explicitly. Set ContextAAD when the Signer is created, for example:
+//
+//	s.ContextAAD = signer.ContextKeys(traceIDKey, tenantKey)
+//
+// If ContextAAD is nil, no values are bound and SignCtx is like Sign.
+func (s *Signer) SignCtx(ctx context.Context, msg, nonce []byte) (Token, error) {
+	return s.SignWithAAD(msg, s.contextAAD(ctx), nonce)
+}
+
+// VerifyCtx is like VerifyWithAAD, with the associated data taken from ctx
+// by s.ContextAAD. A token signed by SignCtx fails to verify, with an error
+// matching ErrUnverified, unless the values extracted from ctx are the same.
+func (s *Signer) VerifyCtx(ctx context.Context, t Token) ([]byte, error) {
+	return s.VerifyWithAAD(t, s.contextAAD(ctx))
+}
+
+func (s *Signer) contextAAD(ctx context.Context) []byte {
+	if s.ContextAAD == nil {
+		return nil
+	}
+	return s.ContextAAD(ctx)
+}
+
+// ContextKeys returns a ContextAAD function extracting the values of keys
+// from a context, in order. Each value is formatted with fmt.Sprint and
+// prefixed with its length, and a missing value is encoded apart from an
+// empty one, so that distinct sets of values never have the same encoding.
+func ContextKeys(keys ...interface{}) func(ctx context.Context) []byte {
+	return func(ctx context.Context) []byte {
+		var p []byte
+		for _, k := range keys {
+			v := ctx.Value(k)
+			if v == nil {
+				p = append(p, 0)
+				continue
+			}
+			str := fmt.Sprint(v)
+			var n [binary.MaxVarintLen64]byte
+			p = append(p, 1)
+			p = append(p, n[:binary.PutUvarint(n[:], uint64(len(str)))]...)
+			p = append(p, str...)
+		}
+		return p
+	}
+}
@@ -0,0 +1,24 @@
+package signer
+
+import (
+	"crypto/sha256"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// DeriveSigner returns a Signer using a key derived from the master secret
+// with HKDF-SHA256. The info identifies the context, such as a tenant or
+// service name: signers derived with different info are independent, and
+// their tokens do not verify under each other's keys. The salt is optional.
+//
+// The derivation is deterministic: the same master, salt, and info always
+// rebuild the same Signer.
+func DeriveSigner(master, salt, info []byte) (*Signer, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, salt, info), key); err != nil {
+		return nil, err
+	}
+	defer zero(key)
+	return New(key)
+}
@@ -0,0 +1,172 @@
+package signer
+
+import (
+	"errors"
+	"io"
+)
+
+// Envelopes encrypt a msg under a random data key, and store the data key
+// wrapped by the master Signer alongside it:
+//
+//	wrapped[s.TokenSize(KeySize)] payload[...]
+//
+// The wrapped key is a token signed by the master Signer whose msg is the
+// data key. The payload is a Version token signed by the data key whose msg
+// is the envelope's msg. Replacing the master key only requires rewrapping
+// the data key, not re-encrypting the payload.
+
+// SealEnvelope encrypts msg under a new random data key, and wraps the data
+// key with s.
+func (s *Signer) SealEnvelope(msg []byte) (Token, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(s.rand(), key); err != nil {
+		return nil, err
+	}
+	defer zero(key)
+	d, err := New(key)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	d.MaxMsgSize = s.MaxMsgSize
+	payload, err := d.Sign(msg, nil)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := s.Sign(key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(wrapped, payload...), nil
+}
+
+// OpenEnvelope unwraps the data key of an envelope sealed by SealEnvelope,
+// and returns the decrypted msg.
+func (s *Signer) OpenEnvelope(t Token) ([]byte, error) {
+	key, payload, err := s.unwrap(t)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(key)
+	d, err := New(key)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	d.MaxMsgSize = s.MaxMsgSize
+	return d.Verify(payload)
+}
+
+// RewrapEnvelope rewraps the data key of an envelope sealed by s so that it
+// can be opened by to instead. The payload is not decrypted or modified.
+func (s *Signer) RewrapEnvelope(t Token, to *Signer) (Token, error) {
+	key, payload, err := s.unwrap(t)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(key)
+	wrapped, err := to.Sign(key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(wrapped, payload...), nil
+}
+
+// unwrap returns the data key and payload of the envelope t
+func (s *Signer) unwrap(t Token) (key []byte, payload Token, err error) {
+	n := s.TokenSize(KeySize)
+	if len(t) < n {
+		return nil, nil, ErrShort
+	}
+	if key, err = s.Verify(t[:n]); err != nil {
+		return nil, nil, err
+	}
+	return key, t[n:], nil
+}
+
+// Multi-recipient envelopes wrap the data key once for each recipient:
+//
+//	count[1] (len[1] wrapped[len])... payload[...]
+//
+// Each wrapped key is a token signed by a recipient Signer. The recipients
+// may differ in version and so in token size.
+
+// SealMulti encrypts msg under a new random data key, and wraps the data key
+// with each of the recipient signers. Any of them can open the envelope with
+// OpenMulti.
+func SealMulti(msg []byte, signers []*Signer) (Token, error) {
+	if len(signers) == 0 || len(signers) > 255 {
+		return nil, ErrRecipients
+	}
+	s := signers[0]
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(s.rand(), key); err != nil {
+		return nil, err
+	}
+	defer zero(key)
+	d, err := New(key)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	d.MaxMsgSize = s.MaxMsgSize
+	t := Token{byte(len(signers))}
+	for _, r := range signers {
+		wrapped, err := r.Sign(key, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(wrapped) > 255 {
+			return nil, ErrTooLong
+		}
+		t = append(t, byte(len(wrapped)))
+		t = append(t, wrapped...)
+	}
+	payload, err := d.Sign(msg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(t, payload...), nil
+}
+
+// OpenMulti finds the data key of an envelope sealed by SealMulti that is
+// wrapped for s, and returns the decrypted msg. It returns ErrUnverified if
+// s is not one of the recipients, calling OnVerifyFail once. Errors other
+// than those of a key wrapped for another recipient, such as ErrClosed,
+// are returned as they are.
+func (s *Signer) OpenMulti(t Token) ([]byte, error) {
+	if len(t) < 1 {
+		return nil, ErrShort
+	}
+	count, t := int(t[0]), t[1:]
+	var key []byte
+	for i := 0; i < count; i++ {
+		if len(t) < 1 || len(t) < 1+int(t[0]) {
+			return nil, ErrShort
+		}
+		n := int(t[0])
+		if key == nil {
+			k, err := s.openToken(nil, t[1:1+n], nil)
+			switch {
+			case err == nil:
+				key = k
+			case errors.Is(err, ErrUnverified), err == ErrKeyID, err == ErrVersion:
+				// wrapped for another recipient, whose version may differ
+			default:
+				return nil, err
+			}
+		}
+		t = t[1+n:]
+	}
+	if key == nil {
+		return nil, s.fail(ErrUnverified)
+	}
+	defer zero(key)
+	d, err := New(key)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	d.MaxMsgSize = s.MaxMsgSize
+	return d.Verify(t)
+}
@@ -0,0 +1,86 @@
+package signer
+
+import "time"
+
+// Clock tells the time. Set a Signer's Clock to control the time seen by
+// expiry checks, for example in tests:
+//
+//	type fakeClock struct{ t time.Time }
+//
+//	func (c *fakeClock) Now() time.Time { return c.t }
+//
+//	clock := &fakeClock{t: time.Unix(0, 0)}
+//	s.Clock = clock
+//	t, _ := s.SignWithTTL(msg, time.Minute)
+//	clock.t = clock.t.Add(time.Hour)
+//	_, err := s.VerifyFresh(t) // ErrExpired
+type Clock interface {
+	Now() time.Time
+}
+
+// now returns the current time according to the Signer's Clock
+func (s *Signer) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
+	}
+	return s.Clock.Now()
+}
+
+// expirySize is the length of the expiry in the header
+const expirySize = 8
+
+// SignWithTTL signs msg with a random nonce, embedding an expiry of ttl from
+// now. The expiry is a big-endian Unix time (in seconds) stored in the
+// header, so it is authenticated but not encrypted, and can be read without
+// the key by Token.ExpiresAt. Tokens signed with SignWithTTL should be
+// verified with VerifyFresh.
+func (s *Signer) SignWithTTL(msg []byte, ttl time.Duration) (t Token, err error) {
+	if len(msg) > s.maxMsgSize() {
+		return nil, ErrTooLong
+	}
+	nonce, err := s.nonce(nil)
+	if err != nil {
+		return nil, err
+	}
+	e := ext{flags: flagExpiry, expiry: s.now().Add(ttl).Unix()}
+	return s.sign(msg, nil, nonce, e)
+}
+
+// VerifyFresh verifies a token signed with SignWithTTL, returning the msg.
+// If the token is authentic but its expiry has passed, or it has no expiry,
+// VerifyFresh returns ErrExpired.
+func (s *Signer) VerifyFresh(t Token) (msg []byte, err error) {
+	if msg, err = s.Verify(t); err != nil {
+		return nil, err
+	}
+	h, _ := t.parse()
+	if h.flags&flagExpiry == 0 || s.now().After(time.Unix(h.expiry, 0)) {
+		return nil, ErrExpired
+	}
+	return msg, nil
+}
+
+// ExpiresAt returns the expiry embedded in a token signed with SignWithTTL,
+// or false if the token has none. It reads the header without the key, so
+// the expiry is not authenticated: use VerifyFresh to check it.
+func (t Token) ExpiresAt() (time.Time, bool) {
+	h, err := t.parse()
+	if err != nil || h.flags&flagExpiry == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(h.expiry, 0), true
+}
+
+// VerifyMaxAge verifies t, and then returns ErrExpired if more than maxAge
+// has passed since issuedAt. The issue time is supplied by the caller, for
+// example from a database record stored alongside the token, rather than
+// read from the token itself. A token exactly maxAge old is accepted.
+func (s *Signer) VerifyMaxAge(t Token, issuedAt time.Time, maxAge time.Duration) (msg []byte, err error) {
+	if msg, err = s.Verify(t); err != nil {
+		return nil, err
+	}
+	if s.now().Sub(issuedAt) > maxAge {
+		return nil, ErrExpired
+	}
+	return msg, nil
+}
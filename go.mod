@@ -1,5 +1,7 @@
 module github.com/as/signer
 
-go 1.14
+go 1.18
 
 require golang.org/x/crypto v0.0.0-20200423211502-4bdfaf469ed5
+
+require golang.org/x/sys v0.0.0-20190412213103-97732733099d // indirect
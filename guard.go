@@ -0,0 +1,53 @@
+package signer
+
+import (
+	"container/list"
+	"sync"
+)
+
+// NewNonceGuard returns a NonceGuard that remembers the n most recently
+// used nonces. It panics if n is less than 1, as such a guard would
+// remember nothing.
+func NewNonceGuard(n int) *NonceGuard {
+	if n < 1 {
+		panic("signer: bad nonce guard size")
+	}
+	return &NonceGuard{
+		n:     n,
+		seen:  make(map[string]*list.Element, n),
+		order: list.New(),
+	}
+}
+
+// NonceGuard detects reuse of recently used nonces. It is a safety net for
+// callers passing their own nonces to Sign, not a guarantee: it only
+// remembers a bounded number of nonces, and only for the lifetime of
+// the process.
+//
+// A NonceGuard rejects any reuse of a nonce, including re-signing the same
+// msg deterministically. It is safe for concurrent use by multiple
+// goroutines, and may be shared by several Signers.
+type NonceGuard struct {
+	mu    sync.Mutex
+	n     int
+	seen  map[string]*list.Element
+	order *list.List // most recently used first
+}
+
+// Use records the nonce, returning ErrNonceReused if it is already known.
+// Either way, the nonce becomes the most recently used, and once more than
+// n nonces are known, the least recently used is forgotten.
+func (g *NonceGuard) Use(nonce []byte) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if e, ok := g.seen[string(nonce)]; ok {
+		g.order.MoveToFront(e)
+		return ErrNonceReused
+	}
+	g.seen[string(nonce)] = g.order.PushFront(string(nonce))
+	if g.order.Len() > g.n {
+		e := g.order.Back()
+		delete(g.seen, g.order.Remove(e).(string))
+	}
+	return nil
+}
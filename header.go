@@ -0,0 +1,344 @@
+package signer
+
+import (
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+)
+
+// Headers are laid out as follows:
+//
+//	version[1] nonce[n] id[1]? flags[1]? expiry[8]? taglen[1]? labellen[1]? label[labellen]? kind[1]?
+//
+// The nonce size n depends on the version, and the key id is present only
+// for VersionKeyID. If flagExt is set in the version, a flags byte follows,
+// and then the optional fields selected by the flags, in order.
+const (
+	// flagExt is set in the version byte of tokens whose header has a
+	// flags byte
+	flagExt = 0x80
+
+	maxHdrSize = hdrSize + 1 + 1 + expirySize + 1 + 1 + MaxLabelSize + 1
+)
+
+// Header flags
+const (
+	flagCompressed = 1 << iota // the msg was compressed before sealing
+	flagPadded                 // the msg was padded before sealing
+	flagExpiry                 // the header has an expiry
+	flagTruncated              // the header has the length of the truncated tag
+	flagLabel                  // the header has a label
+	flagKind                   // the header has a kind
+	flagTag                    // the token is a tag from Tag, without a msg
+
+	knownFlags    = flagCompressed | flagPadded | flagExpiry | flagTruncated | flagLabel | flagKind | flagTag
+	encodingFlags = flagCompressed | flagPadded
+)
+
+// header is the parsed header of a token
+type header struct {
+	version byte // without flagExt
+	nonce   []byte
+	id      byte
+	ext
+	n int // length of the header
+}
+
+// ext holds the optional header fields
+type ext struct {
+	flags  byte
+	expiry int64 // Unix time in seconds
+	tagLen byte
+	label  []byte
+	kind   byte
+}
+
+// Split splits a token into its version, nonce, and ciphertext (including
+// the tag), without copying or authenticating it. The version has flag
+// bits cleared, and the ciphertext begins after any optional header fields.
+// Verify parses headers the same way. Split returns ErrShort if the token
+// is shorter than its header, or ErrVersion if the version is unknown.
+func Split(t Token) (version byte, nonce, ciphertext []byte, err error) {
+	h, err := t.parse()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return h.version, h.nonce, t[h.n:], nil
+}
+
+// parse parses the token's header with the handler of its version. It
+// does not authenticate it.
+func (t Token) parse() (h header, err error) {
+	if len(t) == 0 {
+		return h, ErrShort
+	}
+	f := format(t[0] &^ flagExt)
+	if f == nil {
+		return h, ErrVersion
+	}
+	return f.decode(t)
+}
+
+// parseStd parses a header in the standard layout, the decode routine of
+// every format registered without a Parse
+func parseStd(f *formatHandler, t Token) (h header, err error) {
+	h.version = t[0] &^ flagExt
+	h.n = f.hdrLen()
+	if len(t) < h.n {
+		return h, ErrShort
+	}
+	h.nonce = t[1 : 1+f.nonceSize]
+	if f.keyID {
+		h.id = t[h.n-1]
+	}
+	if t[0]&flagExt == 0 {
+		return h, nil
+	}
+	if len(t) < h.n+1 {
+		return h, ErrShort
+	}
+	h.flags = t[h.n]
+	h.n++
+	if h.flags&^knownFlags != 0 {
+		return h, ErrVersion
+	}
+	if h.flags&flagExpiry != 0 {
+		if len(t) < h.n+expirySize {
+			return h, ErrShort
+		}
+		h.expiry = int64(binary.BigEndian.Uint64(t[h.n:]))
+		h.n += expirySize
+	}
+	if h.flags&flagTruncated != 0 {
+		if len(t) < h.n+1 {
+			return h, ErrShort
+		}
+		h.tagLen = t[h.n]
+		h.n++
+		if h.tagLen < MinTagBits/8 || h.tagLen >= tagSize {
+			// NewTruncated never makes such a tag
+			return h, ErrVersion
+		}
+	}
+	if h.flags&flagLabel != 0 {
+		if len(t) < h.n+1 || len(t) < h.n+1+int(t[h.n]) {
+			return h, ErrShort
+		}
+		n := int(t[h.n])
+		h.label = t[h.n+1 : h.n+1+n]
+		h.n += 1 + n
+	}
+	if h.flags&flagKind != 0 {
+		if len(t) < h.n+1 {
+			return h, ErrShort
+		}
+		h.kind = t[h.n]
+		h.n++
+	}
+	return h, nil
+}
+
+// parse parses the header of a token signed by s. Tags from Tag are
+// rejected with ErrUnverified, so that one can never pass for a token.
+func (s *Signer) parse(c Token) (h header, err error) {
+	return s.parseKind(c, false)
+}
+
+// parseTag is like parse, but accepts only tags from Tag
+func (s *Signer) parseTag(c Token) (h header, err error) {
+	return s.parseKind(c, true)
+}
+
+func (s *Signer) parseKind(c Token, tag bool) (h header, err error) {
+	if h, err = c.parse(); err != nil {
+		return h, err
+	}
+	if h.version != s.version {
+		return h, ErrVersion
+	}
+	if format(s.version).keyID && h.id != s.id {
+		return h, ErrKeyID
+	}
+	if int(h.tagLen) != s.tagLen {
+		return h, ErrVersion
+	}
+	if (h.flags&flagTag != 0) != tag {
+		return h, ErrUnverified
+	}
+	return h, nil
+}
+
+// header appends the header of a token with the given nonce and optional
+// fields to dst
+func (s *Signer) header(dst, nonce []byte, e ext) []byte {
+	// the tag length is s's, not one copied from another token
+	e.flags &^= flagTruncated
+	e.tagLen = 0
+	if s.tagLen != 0 {
+		e.flags |= flagTruncated
+		e.tagLen = byte(s.tagLen)
+	}
+	v := s.version
+	if e.flags != 0 {
+		v |= flagExt
+	}
+	dst = append(dst, v)
+	dst = append(dst, nonce...)
+	if format(s.version).keyID {
+		dst = append(dst, s.id)
+	}
+	if e.flags == 0 {
+		return dst
+	}
+	dst = append(dst, e.flags)
+	if e.flags&flagExpiry != 0 {
+		var p [expirySize]byte
+		binary.BigEndian.PutUint64(p[:], uint64(e.expiry))
+		dst = append(dst, p[:]...)
+	}
+	if e.flags&flagTruncated != 0 {
+		dst = append(dst, e.tagLen)
+	}
+	if e.flags&flagLabel != 0 {
+		dst = append(dst, byte(len(e.label)))
+		dst = append(dst, e.label...)
+	}
+	if e.flags&flagKind != 0 {
+		dst = append(dst, e.kind)
+	}
+	return dst
+}
+
+// withAAD returns the associated data for a token with header hdr
+func withAAD(hdr, aad []byte) []byte {
+	if len(aad) == 0 {
+		return hdr
+	}
+	return append(hdr[:len(hdr):len(hdr)], aad...)
+}
+
+// hdrLen returns the length of the header of tokens signed by s, without
+// optional fields other than those s always includes
+func (s *Signer) hdrLen() int {
+	n := format(s.version).hdrLen()
+	if s.tagLen != 0 {
+		n += 2
+	}
+	return n
+}
+
+// Format describes the header of a token version, for RegisterFormat
+type Format struct {
+	// NonceSize is the length of the nonce, which follows the version byte
+	NonceSize int
+
+	// Parse, if not nil, replaces the standard layout for the version, for
+	// verifying tokens made elsewhere. It returns the token's nonce, and
+	// the length n of its header, which is authenticated as associated
+	// data and followed by the ciphertext and tag, or an error such as
+	// ErrShort. Such tokens have no optional fields, such as an expiry or
+	// label, and Signers for the version can verify tokens, but not sign.
+	Parse func(t Token) (nonce []byte, n int, err error)
+}
+
+// formatHandler is the registered handler of one version. It owns the
+// decoding of the version's headers, and describes the fixed fields,
+// which precede the flags byte, for signing in the standard layout.
+type formatHandler struct {
+	nonceSize int
+	keyID     bool // a key id follows the nonce
+	decode    func(t Token) (header, error)
+	custom    bool // decode is a Parse from RegisterFormat
+}
+
+// hdrLen returns the length of the fixed fields, including the version
+func (f *formatHandler) hdrLen() int {
+	n := 1 + f.nonceSize
+	if f.keyID {
+		n++
+	}
+	return n
+}
+
+var (
+	// formats holds a map[byte]*formatHandler, the handler of each
+	// registered version. It is copied on registration, so that parsing
+	// does not take a lock.
+	formats   atomic.Value
+	formatsMu sync.Mutex // held while registering
+)
+
+func init() {
+	formats.Store(map[byte]*formatHandler{})
+	for v, f := range map[byte]*formatHandler{
+		Version:      {nonceSize: NonceSize},
+		VersionKeyID: {nonceSize: NonceSize, keyID: true},
+		VersionGCM:   {nonceSize: gcmNonceSize},
+		VersionIETF:  {nonceSize: gcmNonceSize},
+	} {
+		if err := registerFormat(v, f); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// format returns the handler of a version, or nil if it is unregistered
+func format(version byte) *formatHandler {
+	return formats.Load().(map[byte]*formatHandler)[version]
+}
+
+// RegisterFormat adds a token version, so that Signers from NewWithFormat
+// can use it, and Verify dispatches its tokens to f. Tokens of
+// unregistered versions fail with ErrVersion. It returns ErrVersion if the
+// version has the high bit (0x80) set, which marks a flags byte in the
+// standard layout, or is already registered, and ErrNonceLen unless the
+// nonce is 1 to NonceSize bytes long. It is safe for concurrent use.
+func RegisterFormat(version byte, f Format) error {
+	if f.NonceSize < 1 || f.NonceSize > NonceSize {
+		return ErrNonceLen
+	}
+	h := &formatHandler{nonceSize: f.NonceSize}
+	if f.Parse != nil {
+		h.custom = true
+		h.decode = customParse(version, f.NonceSize, f.Parse)
+	}
+	return registerFormat(version, h)
+}
+
+// registerFormat adds the handler for a version, decoding the standard
+// layout unless it has its own decode routine
+func registerFormat(version byte, h *formatHandler) error {
+	if version&flagExt != 0 {
+		return ErrVersion
+	}
+	if h.decode == nil {
+		h.decode = func(t Token) (header, error) { return parseStd(h, t) }
+	}
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	old := formats.Load().(map[byte]*formatHandler)
+	if old[version] != nil {
+		return ErrVersion
+	}
+	m := make(map[byte]*formatHandler, len(old)+1)
+	for v, h := range old {
+		m[v] = h
+	}
+	m[version] = h
+	formats.Store(m)
+	return nil
+}
+
+// customParse returns the decode routine of a format with its own parse
+func customParse(version byte, nonceSize int, parse func(Token) ([]byte, int, error)) func(Token) (header, error) {
+	return func(t Token) (h header, err error) {
+		nonce, n, err := parse(t)
+		if err != nil {
+			return h, err
+		}
+		if len(nonce) != nonceSize || n < 1 || n > len(t) {
+			return h, ErrVersion
+		}
+		return header{version: version, nonce: nonce, n: n}, nil
+	}
+}
@@ -0,0 +1,66 @@
+package signer
+
+import (
+	"io"
+	"io/ioutil"
+)
+
+// SignReader reads r to EOF and signs its contents with a random nonce. If
+// r holds more than the Signer's MaxMsgSize, SignReader returns ErrTooLong.
+// Unlike NewEncryptWriter, the whole msg is held in memory.
+func (s *Signer) SignReader(r io.Reader) (Token, error) {
+	max := s.maxMsgSize()
+	msg, err := ioutil.ReadAll(io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(msg) > max {
+		return nil, ErrTooLong
+	}
+	return s.Sign(msg, nil)
+}
+
+// VerifyWriter verifies t and writes its msg to w. Nothing is written
+// unless t is authentic. Errors from w are returned as-is.
+func (s *Signer) VerifyWriter(w io.Writer, t Token) error {
+	msg, err := s.Verify(t)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	return err
+}
+
+// verifyChunkSize is the largest write VerifyToWriter makes
+const verifyChunkSize = 32 << 10
+
+// VerifyToWriter is like VerifyWriter, for tokens with a large msg: once t
+// is verified, its msg is written to w in chunks, as by io.Copy, and then
+// cleared. It returns the number of bytes written. Nothing is written
+// unless t is authentic; a token that fails to verify returns the error
+// from Verify. Errors from w are returned as-is, with the bytes written
+// before them, and a short write without an error returns io.ErrShortWrite.
+func (s *Signer) VerifyToWriter(w io.Writer, t Token) (int64, error) {
+	msg, err := s.Verify(t)
+	if err != nil {
+		return 0, err
+	}
+	defer zero(msg)
+	var n int64
+	for p := msg; len(p) > 0; {
+		m := len(p)
+		if m > verifyChunkSize {
+			m = verifyChunkSize
+		}
+		k, err := w.Write(p[:m])
+		n += int64(k)
+		if err == nil && k < m {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return n, err
+		}
+		p = p[m:]
+	}
+	return n, nil
+}
@@ -0,0 +1,34 @@
+package signer
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SignJSON signs v encoded as JSON by encoding/json, which sorts map keys,
+// so equal values of a type encode identically. If v can not be encoded,
+// it returns an error matching ErrMarshal.
+func SignJSON[T any](s *Signer, v T) (Token, error) {
+	p, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMarshal, err)
+	}
+	return s.Sign(p, nil)
+}
+
+// VerifyJSON verifies t and decodes its msg, signed by SignJSON, into a T.
+// The msg is only decoded once it is verified, so a token that fails to
+// verify returns the error from Verify, and the zero T. A verified msg
+// that can not be decoded into a T returns an error matching ErrUnmarshal.
+func VerifyJSON[T any](s *Signer, t Token) (T, error) {
+	var v T
+	p, err := s.Verify(t)
+	if err != nil {
+		return v, err
+	}
+	if err = json.Unmarshal(p, &v); err != nil {
+		var none T
+		return none, fmt.Errorf("%w: %v", ErrUnmarshal, err)
+	}
+	return v, nil
+}
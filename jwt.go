@@ -0,0 +1,122 @@
+package signer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// jwtHeader is the encoded header of every JWT signed by SignJWT
+var jwtHeader = codec.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// jwtInfo is the HKDF info deriving the HS256 key from a Signer's key
+const jwtInfo = "signer jwt hs256"
+
+// SignJWT returns claims as a compact JWT (RFC 7519) signed with HS256,
+// HMAC-SHA256 under a key derived from the Signer's key, for systems that
+// only accept JWTs.
+//
+// Unlike Sign, this only authenticates the claims: they are base64-encoded
+// JSON that anyone holding the JWT can read. The partner verifying the JWT
+// must be given the key returned by JWTKey, never the Signer's own key,
+// which would let it decrypt and forge every token of the Signer. A Signer
+// created with NewWithAEAD has no key, and returns ErrNoKey.
+func (s *Signer) SignJWT(claims map[string]interface{}) (string, error) {
+	if err := s.canJWT(); err != nil {
+		return "", err
+	}
+	p, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	msg := jwtHeader + "." + codec.EncodeToString(p)
+	return msg + "." + codec.EncodeToString(s.jwtMAC(msg)), nil
+}
+
+// VerifyJWT verifies a JWT signed with HS256 under the key returned by
+// JWTKey, such as one from SignJWT, and returns its claims. A JWT with any other
+// algorithm, including "none", returns ErrVersion, and one whose signature
+// does not match returns ErrUnverified. If the claims have an "exp" that has
+// passed, VerifyJWT returns ErrExpired.
+func (s *Signer) VerifyJWT(token string) (map[string]interface{}, error) {
+	if err := s.canJWT(); err != nil {
+		return nil, err
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrEncoding
+	}
+	var hdr struct {
+		Alg string `json:"alg"`
+	}
+	p, err := codec.DecodeString(parts[0])
+	if err != nil {
+		return nil, encodingError(err)
+	}
+	if err = json.Unmarshal(p, &hdr); err != nil {
+		return nil, encodingError(err)
+	}
+	if hdr.Alg != "HS256" {
+		return nil, ErrVersion
+	}
+	mac, err := codec.DecodeString(parts[2])
+	if err != nil {
+		return nil, encodingError(err)
+	}
+	if !hmac.Equal(mac, s.jwtMAC(parts[0]+"."+parts[1])) {
+		return nil, s.fail(ErrUnverified)
+	}
+	if p, err = codec.DecodeString(parts[1]); err != nil {
+		return nil, encodingError(err)
+	}
+	var claims map[string]interface{}
+	if err = json.Unmarshal(p, &claims); err != nil {
+		return nil, encodingError(err)
+	}
+	if exp, ok := claims["exp"].(float64); ok && !s.now().Before(time.Unix(int64(exp), 0)) {
+		return nil, ErrExpired
+	}
+	return claims, nil
+}
+
+// JWTKey returns a copy of the HS256 key of SignJWT and VerifyJWT, for a
+// partner that verifies or signs the Signer's JWTs. It is derived from the
+// Signer's key with HKDF-SHA256, so it reveals nothing of that key, and can
+// not be used to open or forge the Signer's tokens.
+func (s *Signer) JWTKey() ([]byte, error) {
+	if err := s.canJWT(); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), s.jwt...), nil
+}
+
+// canJWT returns an error if s can not sign or verify JWTs
+func (s *Signer) canJWT() error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+	if s.key == nil {
+		return ErrNoKey
+	}
+	return nil
+}
+
+// jwtSubkey derives the HS256 key from key
+func jwtSubkey(key []byte) []byte {
+	p := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte(jwtInfo)), p); err != nil {
+		panic(err) // HKDF-SHA256 can expand to far more than 32 bytes
+	}
+	return p
+}
+
+func (s *Signer) jwtMAC(msg string) []byte {
+	h := hmac.New(sha256.New, s.jwt)
+	h.Write([]byte(msg))
+	return h.Sum(nil)
+}
@@ -0,0 +1,203 @@
+package signer
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// NewKeyring returns a Keyring holding a Signer for each key. The first key
+// is used for signing, and all keys are tried, in order, during verification.
+func NewKeyring(keys ...[]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKey
+	}
+	k := &Keyring{signer: make([]*Signer, len(keys))}
+	for i, key := range keys {
+		s, err := New(key)
+		if err != nil {
+			return nil, err
+		}
+		k.signer[i] = s
+	}
+	return k, nil
+}
+
+// Keyring signs with one key and verifies against several. It supports key
+// rotation: add the new key to the front of the ring, roll it out, and then
+// remove the old key once its tokens are no longer in use.
+type Keyring struct {
+	signer []*Signer
+}
+
+// Sign signs msg with the first key in the ring. See Signer.Sign.
+func (k *Keyring) Sign(msg []byte, nonce []byte) (Token, error) {
+	return k.signer[0].Sign(msg, nonce)
+}
+
+// Verify tries each key in order, returning the msg decrypted by the first
+// key that verifies the token. If no key verifies the token, Verify returns
+// ErrUnverified. Malformed tokens return the error from the first key.
+func (k *Keyring) Verify(t Token) (msg []byte, err error) {
+	for _, s := range k.signer {
+		msg, err = s.Verify(t)
+		if err == nil {
+			return msg, nil
+		}
+		if !errors.Is(err, ErrUnverified) {
+			return nil, err
+		}
+	}
+	return nil, ErrUnverified
+}
+
+// NewKeyedVerifier returns a KeyedVerifier for the given signers. Signers
+// created with NewWithKeyID verify tokens carrying their key id, and a signer
+// created with New verifies tokens without a key id. If two signers share an
+// id, the last one is used.
+func NewKeyedVerifier(signers ...*Signer) *KeyedVerifier {
+	k := &KeyedVerifier{}
+	for _, s := range signers {
+		if s.version == VersionKeyID {
+			k.keyed[s.id] = s
+		} else {
+			k.legacy = s
+		}
+	}
+	return k
+}
+
+// KeyedVerifier verifies tokens against many keys, using the key id in
+// the token header to select a key in constant time.
+type KeyedVerifier struct {
+	legacy *Signer
+	keyed  [256]*Signer
+}
+
+// Verify verifies the token with the key selected by its key id. Tokens
+// without a key id are verified with the signer passed to NewKeyedVerifier
+// that has none. If no key is available, Verify returns ErrKeyID.
+func (k *KeyedVerifier) Verify(t Token) (msg []byte, err error) {
+	h, err := t.parse()
+	if err != nil {
+		return nil, err
+	}
+	var s *Signer
+	switch h.version {
+	case Version:
+		s = k.legacy
+	case VersionKeyID:
+		s = k.keyed[h.id]
+	default:
+		return nil, ErrVersion
+	}
+	if s == nil {
+		return nil, ErrKeyID
+	}
+	return s.Verify(t)
+}
+
+// KeyEntry is a key used by a RotatingSigner until NotAfter
+type KeyEntry struct {
+	Key      []byte
+	NotAfter time.Time
+}
+
+// NewRotatingSigner returns a RotatingSigner for the given keys. The key
+// with the latest NotAfter is considered the newest.
+func NewRotatingSigner(keys []KeyEntry) (*RotatingSigner, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKey
+	}
+	r := &RotatingSigner{keys: make([]rotatingKey, len(keys))}
+	for i, k := range keys {
+		s, err := New(k.Key)
+		if err != nil {
+			return nil, err
+		}
+		r.keys[i] = rotatingKey{s: s, notAfter: k.NotAfter}
+	}
+	sort.SliceStable(r.keys, func(i, j int) bool {
+		return r.keys[i].notAfter.After(r.keys[j].notAfter)
+	})
+	return r, nil
+}
+
+// NewRotating returns a Signer for the common case of rotating between two
+// keys, without a Keyring: it signs with current, and its Verify tries
+// current and then previous, returning ErrUnverified if neither verifies
+// the token. A nil previous returns a Signer like New. Both keys must be
+// 32 bytes long, otherwise ErrKeyLen is returned.
+//
+// Only one-shot tokens are verified with previous: streams, and the
+// Signer's other features, such as SignDeterministic and SignJWT, use
+// current alone.
+func NewRotating(current, previous []byte) (*Signer, error) {
+	s, err := New(current)
+	if err != nil || previous == nil {
+		return s, err
+	}
+	p, err := New(previous)
+	if err != nil {
+		return nil, err
+	}
+	s.prev = p.aead
+	p.Close()
+	return s, nil
+}
+
+// RotatingSigner signs with its newest key and verifies with every key that
+// has not passed its NotAfter time, newest first. Retiring a key is
+// scheduled by its NotAfter, after which tokens signed with it no longer
+// verify.
+type RotatingSigner struct {
+	// Clock, if not nil, tells the time for NotAfter checks. Otherwise,
+	// the system clock is used.
+	Clock Clock
+
+	keys []rotatingKey // newest first
+}
+
+type rotatingKey struct {
+	s        *Signer
+	notAfter time.Time
+}
+
+// Sign signs msg with the newest key that has not passed its NotAfter time.
+// If every key has expired, Sign returns ErrNoKey.
+func (r *RotatingSigner) Sign(msg []byte, nonce []byte) (Token, error) {
+	now := r.now()
+	for _, k := range r.keys {
+		if !now.After(k.notAfter) {
+			return k.s.Sign(msg, nonce)
+		}
+	}
+	return nil, ErrNoKey
+}
+
+// Verify tries each key that has not passed its NotAfter time, newest
+// first, returning the msg decrypted by the first key that verifies the
+// token. If no key verifies the token, Verify returns ErrUnverified.
+func (r *RotatingSigner) Verify(t Token) (msg []byte, err error) {
+	now := r.now()
+	for _, k := range r.keys {
+		if now.After(k.notAfter) {
+			continue
+		}
+		msg, err = k.s.Verify(t)
+		if err == nil {
+			return msg, nil
+		}
+		if !errors.Is(err, ErrUnverified) {
+			return nil, err
+		}
+	}
+	return nil, ErrUnverified
+}
+
+func (r *RotatingSigner) now() time.Time {
+	if r.Clock == nil {
+		return time.Now()
+	}
+	return r.Clock.Now()
+}
@@ -0,0 +1,36 @@
+package signer
+
+// SignKind is like Sign, but stores kind in the token's header, so that an
+// application multiplexing several types of token through one key can tell
+// them apart. The kind is authenticated but not encrypted, so it can be
+// read without the key by Kind, to route a token before verifying it.
+func (s *Signer) SignKind(kind byte, msg, nonce []byte) (t Token, err error) {
+	return s.SignOpts(msg, SignOptions{Nonce: nonce, Kind: kind, HasKind: true})
+}
+
+// Kind returns the kind of a token signed with SignKind, or ErrNoKind if
+// the token has none. It reads the header without the key, so the kind is
+// not authenticated: use VerifyKind to check it.
+func Kind(t Token) (byte, error) {
+	h, err := t.parse()
+	if err != nil {
+		return 0, err
+	}
+	if h.flags&flagKind == 0 {
+		return 0, ErrNoKind
+	}
+	return h.kind, nil
+}
+
+// VerifyKind verifies a token signed with SignKind, returning its kind and
+// the msg. An authentic token with no kind returns ErrNoKind.
+func (s *Signer) VerifyKind(t Token) (kind byte, msg []byte, err error) {
+	if msg, err = s.Verify(t); err != nil {
+		return 0, nil, err
+	}
+	h, _ := t.parse()
+	if h.flags&flagKind == 0 {
+		return 0, nil, ErrNoKind
+	}
+	return h.kind, msg, nil
+}
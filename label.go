@@ -0,0 +1,43 @@
+package signer
+
+// MaxLabelSize is the length of the longest label accepted by SignLabeled
+const MaxLabelSize = 255
+
+// SignLabeled is like Sign, but stores label in the token's header. The
+// label is authenticated but not encrypted, so it can be read without the
+// key by Label, for example by a load balancer routing on it. It returns
+// ErrTooLong if the label is longer than MaxLabelSize.
+func (s *Signer) SignLabeled(label, msg, nonce []byte) (t Token, err error) {
+	if label == nil {
+		label = []byte{}
+	}
+	return s.SignOpts(msg, SignOptions{Nonce: nonce, Label: label})
+}
+
+// Label returns a copy of the label in a token signed with SignLabeled, or
+// nil if the token has none. It reads the header without the key, so the
+// label is not authenticated: use VerifyLabeled to check it.
+func Label(t Token) ([]byte, error) {
+	h, err := t.parse()
+	if err != nil {
+		return nil, err
+	}
+	return copyLabel(h), nil
+}
+
+// VerifyLabeled verifies a token signed with SignLabeled, returning a copy
+// of its label and the msg. Both are authentic if err is nil.
+func (s *Signer) VerifyLabeled(t Token) (label, msg []byte, err error) {
+	if msg, err = s.Verify(t); err != nil {
+		return nil, nil, err
+	}
+	h, _ := t.parse()
+	return copyLabel(h), msg, nil
+}
+
+func copyLabel(h header) []byte {
+	if h.flags&flagLabel == 0 {
+		return nil
+	}
+	return append([]byte{}, h.label...)
+}
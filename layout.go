@@ -0,0 +1,45 @@
+package signer
+
+// ValidateLayout checks the structure of t without a key: its version must
+// be known, its header complete for that version and its flags, and the
+// rest of t long enough to hold the tag, which is 16 bytes, as for every
+// built-in AEAD, unless t records a truncated tag. It returns ErrVersion
+// for an unknown version or flag, ErrShort for an incomplete header, and
+// ErrTruncatedTag, which matches ErrShort, for a missing tag. Otherwise,
+// Verify with a Signer for t's version and key gets as far as opening t.
+//
+// This is NOT a verification. It is for callers and fuzzers wanting to
+// check tokens structurally, apart from any key.
+func ValidateLayout(t Token) error {
+	return validateLayout(t)
+}
+
+// validateLayout is ValidateLayout
+func validateLayout(t Token) error {
+	h, err := t.parse()
+	if err != nil {
+		return err
+	}
+	tag := tagSize
+	if h.flags&flagTruncated != 0 {
+		tag = int(h.tagLen)
+	}
+	return checkTag(t, h, tag)
+}
+
+// validateLayout is like ValidateLayout for a token with header h, already
+// parsed by s, but with the tag size of s's AEAD, which may be shorter
+// than 16 bytes for one passed to NewWithAEAD. Verify calls it, so that
+// it accepts the layout ValidateLayout documents.
+func (s *Signer) validateLayout(t Token, h header) error {
+	return checkTag(t, h, s.Overhead())
+}
+
+// checkTag returns ErrTruncatedTag unless t has room for a tag of the given
+// size after its header h
+func checkTag(t Token, h header, tag int) error {
+	if len(t)-h.n < tag {
+		return ErrTruncatedTag
+	}
+	return nil
+}
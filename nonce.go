@@ -0,0 +1,183 @@
+package signer
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	nonceTimeSize = 8
+	minNonceRand  = 8 // random bytes in a nonce from NonceFromTime
+)
+
+// NonceFromTime returns a NonceSize nonce for Sign that begins with t, as a
+// big-endian Unix time in seconds, followed by extra, and then bytes from
+// crypto/rand filling the rest. At least 8 bytes are random, so extra may
+// be at most 8 bytes long, otherwise ErrNonceLen is returned.
+//
+// The time lets a nonce be bucketed by when it was made, for debugging or
+// approximate expiry. Two calls at the same instant with the same extra
+// still produce distinct nonces, from the random bytes. The time is not
+// authenticated until the token is verified.
+func NonceFromTime(t time.Time, extra []byte) ([]byte, error) {
+	if len(extra) > NonceSize-nonceTimeSize-minNonceRand {
+		return nil, ErrNonceLen
+	}
+	p := make([]byte, NonceSize)
+	binary.BigEndian.PutUint64(p, uint64(t.Unix()))
+	n := nonceTimeSize + copy(p[nonceTimeSize:], extra)
+	if _, err := io.ReadFull(rand.Reader, p[n:]); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NonceTime returns the time of a nonce made by NonceFromTime
+func NonceTime(nonce []byte) (time.Time, error) {
+	if len(nonce) != NonceSize {
+		return time.Time{}, ErrNonceLen
+	}
+	return time.Unix(int64(binary.BigEndian.Uint64(nonce)), 0), nil
+}
+
+// NewCounterNonce returns a CounterNonce with a random prefix from
+// crypto/rand, and its counter at zero.
+func NewCounterNonce() (*CounterNonce, error) {
+	c := &CounterNonce{}
+	if err := c.Refresh(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// CounterNonce is a nonce source for Signer.Rand that produces successive
+// nonces without reading from a random source. Each nonce is a fixed random
+// prefix, chosen when the CounterNonce is made, followed by a 64-bit
+// big-endian counter that is incremented on every Read.
+//
+// The nonces are only unique for a single writer: two CounterNonces, or two
+// processes restoring the same prefix and counter, can produce the same
+// nonce. Use one CounterNonce for every Signer sharing a key, and do not
+// let it outlive the process. Read returns ErrNonceExhausted rather than
+// wrapping the counter. Streams can not be written by a Signer using a
+// CounterNonce, as their frame nonces count up in the same space.
+//
+// The exported fields configure the CounterNonce, and must not be changed
+// once it is in use.
+type CounterNonce struct {
+	// Limit, if not zero, is the number of nonces that may be produced
+	// before Read returns ErrNonceExhausted. Otherwise, the limit is
+	// 2^64-1.
+	Limit uint64
+
+	// OnNonceThreshold, if not nil, is called once with the number of
+	// nonces remaining when fewer than Threshold*Limit remain, as a
+	// warning to rotate the key before the nonces run out. Threshold is a
+	// fraction between 0 and 1.
+	OnNonceThreshold func(remaining uint64)
+	Threshold        float64
+
+	mu     sync.Mutex
+	prefix [NonceSize - 8]byte
+	n      uint64
+	warned bool
+}
+
+// Read fills p with the next nonce. The prefix is truncated to fit p, so p
+// must be 9 to NonceSize bytes long, otherwise ErrNonceLen is returned.
+func (c *CounterNonce) Read(p []byte) (int, error) {
+	if len(p) <= 8 || len(p) > NonceSize {
+		return 0, ErrNonceLen
+	}
+	limit := c.Limit
+	if limit == 0 {
+		limit = math.MaxUint64
+	}
+	c.mu.Lock()
+	if c.n >= limit {
+		c.mu.Unlock()
+		return 0, ErrNonceExhausted
+	}
+	k := copy(p, c.prefix[:len(p)-8])
+	binary.BigEndian.PutUint64(p[k:], c.n)
+	c.n++
+	remaining := limit - c.n
+	warn := !c.warned && c.OnNonceThreshold != nil && float64(remaining) < c.Threshold*float64(limit)
+	if warn {
+		c.warned = true
+	}
+	c.mu.Unlock()
+	if warn {
+		c.OnNonceThreshold(remaining)
+	}
+	return len(p), nil
+}
+
+// Refresh draws a new random prefix from crypto/rand and resets the
+// counter to zero, as if c were new, so OnNonceThreshold may be called
+// again. On error, c is unchanged.
+func (c *CounterNonce) Refresh() error {
+	var prefix [NonceSize - 8]byte
+	if _, err := io.ReadFull(rand.Reader, prefix[:]); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.prefix, c.n, c.warned = prefix, 0, false
+	c.mu.Unlock()
+	return nil
+}
+
+// RefreshNoncePrefix refreshes s.Rand if it is a CounterNonce, giving it a
+// new random prefix and resetting its counter. Otherwise, it does nothing.
+func (s *Signer) RefreshNoncePrefix() error {
+	if c, ok := s.Rand.(*CounterNonce); ok {
+		return c.Refresh()
+	}
+	return nil
+}
+
+// Remaining returns the number of nonces c can still produce
+func (c *CounterNonce) Remaining() uint64 {
+	limit := c.Limit
+	if limit == 0 {
+		limit = math.MaxUint64
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return limit - c.n
+}
+
+// randomNonceBudget is the number of tokens that may be signed with 12-byte
+// random nonces under one key, keeping the chance of a collision below
+// 2^-32, as recommended by NIST SP 800-38D
+const randomNonceBudget = 1 << 32
+
+// SafeSignBudget returns the number of tokens s can still sign before its
+// key should be rotated, based on its nonces:
+//
+//   - with a CounterNonce, the nonces it has left
+//   - with random 24-byte nonces, math.MaxUint64, as collisions are
+//     negligible for any practical number of tokens
+//   - with random 12-byte nonces, 2^32 less the tokens s has signed
+//
+// The count of tokens signed is kept by s alone, so it does not include
+// those signed by other Signers with the same key, such as clones, or by
+// earlier processes. Caller-supplied nonces are not accounted for.
+func (s *Signer) SafeSignBudget() uint64 {
+	if c, ok := s.Rand.(*CounterNonce); ok {
+		return c.Remaining()
+	}
+	if s.NonceSize() >= NonceSize {
+		return math.MaxUint64
+	}
+	n := atomic.LoadUint64(&s.count)
+	if n >= randomNonceBudget {
+		return 0
+	}
+	return randomNonceBudget - n
+}
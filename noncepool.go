@@ -0,0 +1,105 @@
+package signer
+
+import (
+	"crypto/rand"
+	"io"
+	"sync"
+)
+
+// noncePoolBatch is the number of nonces the pool reads from crypto/rand at
+// a time
+const noncePoolBatch = 64
+
+// NewNoncePool returns a NoncePool of up to n nonces of size bytes, which
+// should be the NonceSize of the Signer using it, and starts filling it in
+// the background. It panics unless size is 1 to NonceSize, as a pool of
+// any other size is a programming error.
+func NewNoncePool(size, n int) *NoncePool {
+	if size < 1 || size > NonceSize {
+		panic("signer: bad nonce pool size")
+	}
+	if n < 1 {
+		n = 1
+	}
+	p := &NoncePool{
+		size: size,
+		c:    make(chan []byte, n),
+		done: make(chan struct{}),
+		exit: make(chan struct{}),
+	}
+	go p.fill()
+	return p
+}
+
+// NoncePool is a nonce source for Signer.Rand that reads from crypto/rand
+// ahead of time, in a background goroutine, so that Sign does not wait on
+// the random source while the pool has nonces. When the pool is empty, as
+// when the source can not keep up, nonces are read from crypto/rand
+// directly. Either way, every nonce comes from crypto/rand.
+//
+// A NoncePool is safe for concurrent use by multiple goroutines, and may be
+// shared by several Signers, such as clones, which share Rand. Closing a
+// Signer does not close its Rand: Close the pool once every Signer using
+// it is done, to stop its goroutine and clear the nonces left in it.
+type NoncePool struct {
+	size int
+	c    chan []byte
+	once sync.Once
+	done chan struct{} // closed by Close
+	exit chan struct{} // closed once fill has returned
+}
+
+func (p *NoncePool) fill() {
+	defer close(p.exit)
+	for {
+		buf := make([]byte, p.size*noncePoolBatch)
+		if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+			// Read falls back on reading directly, which returns the error
+			return
+		}
+		for i := 0; i < len(buf); i += p.size {
+			select {
+			case p.c <- buf[i : i+p.size : i+p.size]:
+			case <-p.done:
+				zero(buf[i:]) // the rest belongs to readers
+				return
+			}
+		}
+	}
+}
+
+// Read fills b with pooled nonces, reading from crypto/rand directly when
+// the pool is empty or closed, or for any part of b shorter than a nonce.
+func (p *NoncePool) Read(b []byte) (int, error) {
+	n := 0
+	for len(b)-n >= p.size {
+		select {
+		case nonce := <-p.c:
+			n += copy(b[n:], nonce)
+			zero(nonce)
+		default:
+			m, err := io.ReadFull(rand.Reader, b[n:])
+			return n + m, err
+		}
+	}
+	m, err := io.ReadFull(rand.Reader, b[n:])
+	return n + m, err
+}
+
+// Close stops filling the pool and zeroes the nonces left in it. Later
+// reads go to crypto/rand directly. Close may be called more than once.
+func (p *NoncePool) Close() error {
+	p.once.Do(func() {
+		close(p.done)
+		<-p.exit
+		for {
+			select {
+			case nonce := <-p.c:
+				zero(nonce)
+			default:
+				return
+			}
+		}
+	})
+	return nil
+}
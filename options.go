@@ -0,0 +1,113 @@
+package signer
+
+import (
+	"bytes"
+	"time"
+)
+
+// SignOptions selects the features of a token signed by SignOpts. The zero
+// value signs like Sign with a random nonce.
+//
+// The msg is compressed first, if Compress is set, and then padded, if Pad
+// is set, so padding hides the length of the compressed msg. The header
+// fields (TTL, Label, and Kind) and AAD are authenticated independently of
+// the msg and of each other.
+type SignOptions struct {
+	// AAD is additional data authenticated with the token but not stored
+	// in it, as in SignWithAAD
+	AAD []byte
+
+	// Nonce, if not nil, is the nonce, as in Sign
+	Nonce []byte
+
+	// TTL, if not zero, embeds an expiry of TTL from now, as in
+	// SignWithTTL
+	TTL time.Duration
+
+	// Label, if not nil, is stored in the header, as in SignLabeled
+	Label []byte
+
+	// Kind is stored in the header if HasKind is set, as in SignKind
+	Kind    byte
+	HasKind bool
+
+	// Compress compresses the msg with DEFLATE, as in SignCompressed
+	Compress bool
+
+	// Pad, if not zero, pads the msg to a multiple of Pad bytes, as in
+	// SignPadded
+	Pad int
+}
+
+// SignOpts signs msg with the features selected by opts. SignCompressed,
+// SignPadded, SignLabeled, and SignKind are shorthands for SignOpts with one
+// option set.
+func (s *Signer) SignOpts(msg []byte, opts SignOptions) (t Token, err error) {
+	if opts.Pad < 0 {
+		return nil, ErrBlockSize
+	}
+	if len(msg) > s.maxMsgSize() || len(opts.Label) > MaxLabelSize || opts.Pad > s.maxMsgSize() {
+		return nil, ErrTooLong
+	}
+	if anyOverlap(opts.Nonce, msg) {
+		return nil, ErrOverlap
+	}
+	var e ext
+	p := msg
+	if opts.Compress {
+		if p, err = compress(p); err != nil {
+			return nil, err
+		}
+		e.flags |= flagCompressed
+	}
+	if opts.Pad != 0 {
+		// checked before padding, so a large Pad never allocates
+		if padLen(len(p), opts.Pad) > s.maxMsgSize() {
+			return nil, ErrTooLong
+		}
+		p = pad(p, opts.Pad)
+		e.flags |= flagPadded
+	}
+	nonce, err := s.nonce(opts.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	if opts.TTL != 0 {
+		e.flags |= flagExpiry
+		e.expiry = s.now().Add(opts.TTL).Unix()
+	}
+	if opts.Label != nil {
+		e.flags |= flagLabel
+		e.label = opts.Label
+	}
+	if opts.HasKind {
+		e.flags |= flagKind
+		e.kind = opts.Kind
+	}
+	return s.sign(p, opts.AAD, nonce, e)
+}
+
+// VerifyOpts verifies a token signed by SignOpts with the same AAD, and
+// checks that it has the header fields opts asks for. If opts.TTL is not
+// zero, a token without an expiry, or whose expiry has passed, returns
+// ErrExpired, as in VerifyFresh. If opts.Label is not nil, or opts.HasKind
+// is set, a token without that label or kind returns ErrOptions. The
+// encoding options and the nonce are ignored: the header records the
+// encoding, and Verify undoes it.
+func (s *Signer) VerifyOpts(t Token, opts SignOptions) ([]byte, error) {
+	msg, err := s.VerifyWithAAD(t, opts.AAD)
+	if err != nil {
+		return nil, err
+	}
+	h, _ := t.parse()
+	if opts.TTL != 0 && (h.flags&flagExpiry == 0 || s.now().After(time.Unix(h.expiry, 0))) {
+		return nil, ErrExpired
+	}
+	if opts.Label != nil && (h.flags&flagLabel == 0 || !bytes.Equal(h.label, opts.Label)) {
+		return nil, ErrOptions
+	}
+	if opts.HasKind && (h.flags&flagKind == 0 || h.kind != opts.Kind) {
+		return nil, ErrOptions
+	}
+	return msg, nil
+}
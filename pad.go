@@ -0,0 +1,73 @@
+package signer
+
+// SignPadded is like Sign, but pads msg to a multiple of blockSize bytes
+// before sealing it, so that the token's length reveals only the number of
+// blocks in the msg. Verify removes the padding transparently.
+//
+// The padding is a single 0x80 byte followed by zero or more zero bytes
+// (ISO/IEC 7816-4), so at least one byte is always added: a msg that is
+// already a multiple of blockSize gains a full block.
+func (s *Signer) SignPadded(msg, nonce []byte, blockSize int) (t Token, err error) {
+	if blockSize < 1 {
+		return nil, ErrBlockSize
+	}
+	return s.SignOpts(msg, SignOptions{Nonce: nonce, Pad: blockSize})
+}
+
+// pad returns a padded copy of msg
+func pad(msg []byte, blockSize int) []byte {
+	p := make([]byte, padLen(len(msg), blockSize))
+	copy(p, msg)
+	p[len(msg)] = 0x80
+	return p
+}
+
+// padLen returns the length of a msg of n bytes once padded
+func padLen(n, blockSize int) int {
+	n++
+	if r := n % blockSize; r != 0 {
+		n += blockSize - r
+	}
+	return n
+}
+
+// unpad removes the padding added by pad
+func unpad(p []byte) ([]byte, error) {
+	i := len(p) - 1
+	for i >= 0 && p[i] == 0 {
+		i--
+	}
+	if i < 0 || p[i] != 0x80 {
+		return nil, ErrPadding
+	}
+	return p[:i], nil
+}
+
+// SignUniform is like Sign, but compresses msg with DEFLATE and then pads it
+// to exactly size bytes before sealing it, so that every token signed with
+// the same size has the same length, regardless of the msg. Verify removes
+// the padding and decompresses the msg transparently. It returns ErrTooLong
+// if the compressed msg and at least one byte of padding do not fit in size
+// bytes.
+func (s *Signer) SignUniform(msg, nonce []byte, size int) (t Token, err error) {
+	if size < 1 {
+		return nil, ErrBlockSize
+	}
+	if len(msg) > s.maxMsgSize() || size > s.maxMsgSize() {
+		return nil, ErrTooLong
+	}
+	if anyOverlap(nonce, msg) {
+		return nil, ErrOverlap
+	}
+	c, err := compress(msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(c) >= size {
+		return nil, ErrTooLong
+	}
+	if nonce, err = s.nonce(nonce); err != nil {
+		return nil, err
+	}
+	return s.sign(pad(c, size), nil, nonce, ext{flags: flagCompressed | flagPadded})
+}
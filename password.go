@@ -0,0 +1,42 @@
+package signer
+
+import "golang.org/x/crypto/argon2"
+
+// MinSaltSize is the minimum salt length accepted by NewFromPassword
+const MinSaltSize = 16
+
+// Argon2Params are the Argon2id cost parameters used to stretch a password
+// into a key. See golang.org/x/crypto/argon2.IDKey.
+type Argon2Params struct {
+	Time    uint32 // number of passes over the memory
+	Memory  uint32 // memory size in KiB
+	Threads uint8  // degree of parallelism
+}
+
+// DefaultArgon2Params are the parameters recommended by the argon2 package
+// for Argon2id: one pass over 64 MiB of memory using four threads.
+var DefaultArgon2Params = Argon2Params{Time: 1, Memory: 64 * 1024, Threads: 4}
+
+// maxArgon2Memory is the largest Memory in Argon2Params, 4 GiB
+const maxArgon2Memory = 4 << 20
+
+// NewFromPassword returns a Signer using a key derived from password and
+// salt with Argon2id. The salt must be at least MinSaltSize bytes, or
+// ErrSaltLen is returned. The params must have a Time and Threads of at
+// least 1, and a Memory of at least 8 KiB per thread and at most 4 GiB,
+// or ErrArgon2Params is returned.
+//
+// The derivation is deterministic: the same password, salt, and params
+// always yield the same key, so tokens can be verified by any Signer created
+// with the same inputs.
+func NewFromPassword(password, salt []byte, params Argon2Params) (*Signer, error) {
+	if len(salt) < MinSaltSize {
+		return nil, ErrSaltLen
+	}
+	if params.Time < 1 || params.Threads < 1 || params.Memory < 8*uint32(params.Threads) || params.Memory > maxArgon2Memory {
+		return nil, ErrArgon2Params
+	}
+	key := argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads, KeySize)
+	defer zero(key)
+	return New(key)
+}
@@ -0,0 +1,45 @@
+package signer
+
+import "sync"
+
+// NewSignerPool returns a SignerPool of Signers configured with key, if
+// and only if len(key) == 32.
+func NewSignerPool(key []byte) (*SignerPool, error) {
+	s, err := New(key)
+	if err != nil {
+		return nil, err
+	}
+	key = append([]byte(nil), key...)
+	p := &SignerPool{}
+	p.pool.New = func() interface{} {
+		s, _ := New(key)
+		return s
+	}
+	p.pool.Put(s)
+	return p, nil
+}
+
+// SignerPool is a pool of Signers sharing a key. Each call acquires a
+// Signer from the pool, uses it, and releases it, so no two goroutines use
+// the same Signer at once.
+//
+// A Signer is already safe for concurrent use, so a SignerPool is only
+// needed when callers rely on per-Signer state; Sign and Verify on a single
+// shared Signer scale across cores as well.
+type SignerPool struct {
+	pool sync.Pool
+}
+
+// Sign signs msg with a Signer from the pool. See Signer.Sign.
+func (p *SignerPool) Sign(msg []byte, nonce []byte) (Token, error) {
+	s := p.pool.Get().(*Signer)
+	defer p.pool.Put(s)
+	return s.Sign(msg, nonce)
+}
+
+// Verify verifies t with a Signer from the pool. See Signer.Verify.
+func (p *SignerPool) Verify(t Token) (msg []byte, err error) {
+	s := p.pool.Get().(*Signer)
+	defer p.pool.Put(s)
+	return s.Verify(t)
+}
@@ -0,0 +1,23 @@
+package signer
+
+// SignFor is like Sign, but binds the token to a purpose, such as
+// "download" or "reset-password". The token only verifies with VerifyFor
+// and the same purpose, so tokens issued for one purpose can not be used
+// for another, even though they share a key.
+func (s *Signer) SignFor(purpose string, msg, nonce []byte) (Token, error) {
+	return s.SignWithAAD(msg, purposeAAD(purpose), nonce)
+}
+
+// VerifyFor verifies a token signed by SignFor with the same purpose. A
+// token signed for any other purpose, or with Sign, fails with
+// ErrUnverified.
+func (s *Signer) VerifyFor(purpose string, t Token) ([]byte, error) {
+	return s.VerifyWithAAD(t, purposeAAD(purpose))
+}
+
+// purposeAAD returns the associated data binding a token to purpose. It is
+// prefixed so that it can not collide with aad passed to SignWithAAD by
+// accident.
+func purposeAAD(purpose string) []byte {
+	return append([]byte("signer purpose\x00"), purpose...)
+}
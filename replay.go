@@ -0,0 +1,116 @@
+package signer
+
+import (
+	"sync"
+	"time"
+)
+
+// ReplayGuard remembers the tokens accepted by VerifyOnce, by an ID unique
+// to each token. Implementations backed by a shared store, such as a
+// database, let several processes accept each token once between them.
+type ReplayGuard interface {
+	// Seen reports whether id has been marked
+	Seen(id []byte) bool
+
+	// Mark records id as used
+	Mark(id []byte)
+}
+
+// VerifyOnce verifies t, and then returns ErrReplayed if guard has already
+// seen it, for one-time tokens such as password resets or magic links. A
+// token accepted for the first time is marked in guard. The ID of a token
+// is its nonce, which is unique to each token signed by Sign.
+//
+// Seen and Mark are separate calls, so two goroutines presenting the same
+// token at once may both be accepted by a guard that does not serialize
+// them. With a MemoryReplayGuard, checking and marking is atomic.
+func (s *Signer) VerifyOnce(t Token, guard ReplayGuard) ([]byte, error) {
+	msg, nonce, err := s.VerifyWithNonce(t)
+	if err != nil {
+		return nil, err
+	}
+	if g, ok := guard.(*MemoryReplayGuard); ok {
+		if !g.use(nonce) {
+			return nil, ErrReplayed
+		}
+		return msg, nil
+	}
+	if guard.Seen(nonce) {
+		return nil, ErrReplayed
+	}
+	guard.Mark(nonce)
+	return msg, nil
+}
+
+// NewMemoryReplayGuard returns a MemoryReplayGuard that forgets IDs ttl
+// after they are marked.
+func NewMemoryReplayGuard(ttl time.Duration) *MemoryReplayGuard {
+	return &MemoryReplayGuard{ttl: ttl, seen: make(map[string]time.Time)}
+}
+
+// MemoryReplayGuard is a ReplayGuard that keeps IDs in memory, for the
+// lifetime of the process, until their ttl has passed. Once an ID is
+// forgotten, its token is accepted again, so the ttl must be at least the
+// lifetime of the tokens it guards, such as the TTL passed to SignWithTTL.
+// It is safe for concurrent use by multiple goroutines.
+type MemoryReplayGuard struct {
+	// Clock, if not nil, tells the time for eviction. Otherwise, the system
+	// clock is used.
+	Clock Clock
+
+	mu    sync.Mutex
+	ttl   time.Duration
+	seen  map[string]time.Time // expiry of each ID
+	swept time.Time            // last eviction of expired IDs
+}
+
+// Seen reports whether id was marked less than the guard's ttl ago
+func (g *MemoryReplayGuard) Seen(id []byte) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.seenLocked(id, g.now())
+}
+
+// Mark records id as used
+func (g *MemoryReplayGuard) Mark(id []byte) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.markLocked(id, g.now())
+}
+
+// use marks id, reporting false if it was already seen
+func (g *MemoryReplayGuard) use(id []byte) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	if g.seenLocked(id, now) {
+		return false
+	}
+	g.markLocked(id, now)
+	return true
+}
+
+func (g *MemoryReplayGuard) seenLocked(id []byte, now time.Time) bool {
+	exp, ok := g.seen[string(id)]
+	return ok && now.Before(exp)
+}
+
+func (g *MemoryReplayGuard) markLocked(id []byte, now time.Time) {
+	if now.Sub(g.swept) >= g.ttl {
+		// evict at most once per ttl, so that marking stays cheap
+		for k, exp := range g.seen {
+			if !now.Before(exp) {
+				delete(g.seen, k)
+			}
+		}
+		g.swept = now
+	}
+	g.seen[string(id)] = now.Add(g.ttl)
+}
+
+func (g *MemoryReplayGuard) now() time.Time {
+	if g.Clock == nil {
+		return time.Now()
+	}
+	return g.Clock.Now()
+}
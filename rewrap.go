@@ -0,0 +1,31 @@
+package signer
+
+// Rewrap verifies t and signs its msg again with to, using a fresh nonce,
+// so that tokens can be moved to a new key without exposing their msgs to
+// the caller. Optional header fields, such as an expiry, and any encoding
+// of the msg are preserved. If t does not verify, Rewrap returns the error
+// from Verify, which matches ErrUnverified for inauthentic tokens, and no
+// new token.
+func (s *Signer) Rewrap(t Token, to *Signer) (Token, error) {
+	h, p, err := s.openRaw(nil, t, nil)
+	if err != nil {
+		return nil, s.fail(err)
+	}
+	defer zero(p)
+	if len(p) > to.maxMsgSize() {
+		return nil, ErrTooLong
+	}
+	nonce, err := to.nonce(nil)
+	if err != nil {
+		return nil, err
+	}
+	return to.sign(p, nil, nonce, h.ext)
+}
+
+// Refresh is like Rewrap to s itself: it verifies t and signs its msg again
+// with a fresh nonce, returning a new token with the same msg and header
+// fields. The old token remains valid; a caller wanting it invalidated must
+// track that separately, for example with its nonce.
+func (s *Signer) Refresh(t Token) (Token, error) {
+	return s.Rewrap(t, s)
+}
@@ -0,0 +1,70 @@
+package signer
+
+import "bytes"
+
+// selfTestMsg is signed by SelfTest under an all-zero key and nonce
+const selfTestMsg = "signer self-test"
+
+// selfTestVectors holds the expected token for selfTestMsg, by version.
+// VersionKeyID uses the same AEAD as Version.
+var selfTestVectors = map[byte]struct {
+	new   func(key []byte) (*Signer, error)
+	token string
+}{
+	Version:      {New, "QQAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAv38eeAUq0MvI2V6MFRbDwvyxe2JTwSreCAugscsGu6"},
+	VersionKeyID: {New, "QQAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAv38eeAUq0MvI2V6MFRbDwvyxe2JTwSreCAugscsGu6"},
+	VersionGCM:   {NewAESGCM, "QwAAAAAAAAAAAAAAAL3OJ1MoEksdYiKj_s6W7mxB5yQz2KpboIBtkteNOCQG"},
+	VersionIETF:  {NewStandard, "RAAAAAAAAAAAAAAAAOxugNAwIxgJ_dbxUQdIe3lIvv3rsuCJ6j3OAmo_pzlj"},
+}
+
+// SelfTest checks that the cryptography behind s works. It signs a fixed
+// msg with the built-in AEAD for s's version, under a fixed key and nonce,
+// and compares the token with one recorded in the source. It then signs and
+// verifies a msg with s itself, using a random nonce.
+//
+// An error matching ErrSelfTest means that s, or the crypto library it
+// depends on, is broken: the build is miscompiled, the platform-specific
+// assembly is faulty, or the library has changed its output. Tokens signed
+// by s can not be trusted to interoperate, and s should not be used. Other
+// errors, such as from the randomness source or ErrClosed, do not imply
+// that the cryptography is broken.
+func (s *Signer) SelfTest() error {
+	if v, ok := selfTestVectors[s.version]; ok {
+		kat, err := v.new(make([]byte, KeySize))
+		if err != nil {
+			return err
+		}
+		t, err := kat.Sign([]byte(selfTestMsg), make([]byte, kat.NonceSize()))
+		if err != nil {
+			return err
+		}
+		if t.Encode() != v.token {
+			return ErrSelfTest
+		}
+		if msg, err := kat.Verify(t); err != nil || string(msg) != selfTestMsg {
+			return ErrSelfTest
+		}
+	}
+	t, err := s.Sign([]byte(selfTestMsg), nil)
+	if err != nil {
+		return err
+	}
+	msg, err := s.Verify(t)
+	if err != nil || !bytes.Equal(msg, []byte(selfTestMsg)) {
+		return ErrSelfTest
+	}
+	return nil
+}
+
+// NewChecked is like New, but runs SelfTest on the new Signer, returning
+// its error if it fails
+func NewChecked(key []byte) (*Signer, error) {
+	s, err := New(key)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.SelfTest(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -1,85 +1,880 @@
 package signer
 
 import (
+	"context"
+	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
 
 	"golang.org/x/crypto/chacha20poly1305"
 )
 
 const (
-	Version   = 'A'
-	NonceSize = chacha20poly1305.NonceSizeX // 24
+	Version      = 'A'
+	VersionKeyID = 'B'                         // Version with a key ID following the nonce
+	VersionGCM   = 'C'                         // Version for AES-256-GCM and other 12-byte nonce AEADs
+	VersionIETF  = 'D'                         // Version for IETF ChaCha20-Poly1305
+	NonceSize    = chacha20poly1305.NonceSizeX // 24, the nonce size of Version tokens
+	KeySize      = chacha20poly1305.KeySize    // 32
 
-	hdrSize = 1 + NonceSize
+	// DefaultMaxMsgSize is the largest msg a Signer accepts when its
+	// MaxMsgSize is zero
+	DefaultMaxMsgSize = 64 << 20
+
+	hdrSize      = 1 + NonceSize
+	gcmNonceSize = chacha20poly1305.NonceSize // 12, for both AES-GCM and IETF ChaCha20-Poly1305
+	tagSize      = 16                         // the untruncated tag size of every built-in AEAD
 )
 
 var (
-	ErrKeyLen = errors.New("bad key length")
-	ErrShort  = errors.New("message too short")
+	ErrKeyLen         = errors.New("bad key length")
+	ErrShort          = errors.New("message too short")
+	ErrEncoding       = errors.New("bad token encoding")
+	ErrVersion        = errors.New("unknown token version")
+	ErrNonceLen       = errors.New("bad nonce length")
+	ErrNoKey          = errors.New("no key")
+	ErrKeyID          = errors.New("unknown key id")
+	ErrExpired        = errors.New("token expired")
+	ErrSaltLen        = errors.New("salt too short")
+	ErrArgon2Params   = errors.New("bad argon2 parameters")
+	ErrClosed         = errors.New("closed")
+	ErrTruncated      = errors.New("stream truncated")
+	ErrNonceReused    = errors.New("nonce reused")
+	ErrTooLong        = errors.New("message too long")
+	ErrNoCookie       = errors.New("no signed cookie")
+	ErrPadding        = errors.New("bad padding")
+	ErrBlockSize      = errors.New("bad block size")
+	ErrArmor          = errors.New("bad armor")
+	ErrTagSize        = errors.New("bad tag size")
+	ErrRecipients     = errors.New("need 1 to 255 recipients")
+	ErrNonceExhausted = errors.New("nonce counter exhausted")
+	ErrSelfTest       = errors.New("self-test failed")
+	ErrNoKind         = errors.New("token has no kind")
+	ErrOptions        = errors.New("token does not match options")
+	ErrOverlap        = errors.New("buffers overlap")
+	ErrChainOrder     = errors.New("chain entry missing or out of order")
+	ErrFrameSize      = errors.New("bad frame size")
+	ErrStreamNonce    = errors.New("counter nonces can not be used for streams")
+	ErrMarshal        = errors.New("can not marshal msg")
+	ErrUnmarshal      = errors.New("can not unmarshal msg")
+	ErrReplayed       = errors.New("token already used")
+	ErrTokenSize      = errors.New("token not of the wanted size")
+	ErrInternal       = errors.New("internal error")
+
+	ErrUnverified = errors.New("token not verified")
+
+	// ErrTruncatedTag is returned for tokens too short to hold a whole
+	// tag after the header, usually because they were cut off in transit.
+	// It matches ErrShort with errors.Is. A token missing part of its tag
+	// is only detected if its msg is shorter than the missing part;
+	// otherwise, it fails to verify with ErrUnverified.
+	ErrTruncatedTag = fmt.Errorf("%w: truncated tag", ErrShort)
 )
 
+// unverified wraps an error returned by the AEAD's Open. It matches
+// ErrUnverified with errors.Is, and unwraps to the AEAD error.
+type unverified struct {
+	err error
+}
+
+// internal wraps a value recovered from a panic in the AEAD. It matches
+// ErrInternal with errors.Is, and unwraps to the value if it is an error.
+type internal struct {
+	v interface{}
+}
+
+func (e internal) Error() string        { return fmt.Sprint(ErrInternal.Error()+": ", e.v) }
+func (e internal) Is(target error) bool { return target == ErrInternal }
+func (e internal) Unwrap() error {
+	err, _ := e.v.(error)
+	return err
+}
+
+// recovered, deferred by sealAEAD and openAEAD, turns a panic into an
+// error matching ErrInternal, so that no input can crash the caller
+func recovered(err *error) {
+	if v := recover(); v != nil {
+		*err = internal{v}
+	}
+}
+
+// sealAEAD is aead.Seal, but returns a panic as an error matching
+// ErrInternal. Only the AEAD is guarded, so a panic in a hook such as
+// OnSign is not mistaken for one.
+func sealAEAD(aead cipher.AEAD, dst, nonce, msg, ad []byte) (p []byte, err error) {
+	defer recovered(&err)
+	return aead.Seal(dst, nonce, msg, ad), nil
+}
+
+// openAEAD is aead.Open, but returns a panic as an error matching
+// ErrInternal, like sealAEAD
+func openAEAD(aead cipher.AEAD, dst, nonce, ae, ad []byte) (p []byte, err error) {
+	defer recovered(&err)
+	return aead.Open(dst, nonce, ae, ad)
+}
+
+func (e unverified) Error() string        { return ErrUnverified.Error() + ": " + e.err.Error() }
+func (e unverified) Is(target error) bool { return target == ErrUnverified }
+func (e unverified) Unwrap() error        { return e.err }
+
 // New returns a Signer configured with key, if and only if len(key) == 32
 func New(key []byte) (*Signer, error) {
+	if len(key) != KeySize {
+		return nil, ErrKeyLen
+	}
 	aead, err := chacha20poly1305.NewX(key)
 	if err != nil {
 		return nil, err
 	}
-	return &Signer{aead: aead}, nil
+	s := &Signer{aead: aead, version: Version}
+	s.setKey(key)
+	return s, nil
+}
+
+// NewAESGCM returns a Signer using AES-256-GCM, if and only if
+// len(key) == 32. Its tokens have version VersionGCM and a 12-byte nonce.
+func NewAESGCM(key []byte) (*Signer, error) {
+	if len(key) != KeySize {
+		return nil, ErrKeyLen
+	}
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(b)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewWithAEAD(aead)
+	if err != nil {
+		return nil, err
+	}
+	s.setKey(key)
+	return s, nil
+}
+
+// NewStandard returns a Signer using the IETF variant of ChaCha20-Poly1305
+// (RFC 8439), if and only if len(key) == 32. Its tokens have version
+// VersionIETF and a 12-byte nonce, for interoperability with systems that
+// do not support XChaCha20-Poly1305.
+func NewStandard(key []byte) (*Signer, error) {
+	if len(key) != KeySize {
+		return nil, ErrKeyLen
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	s := &Signer{aead: aead, version: VersionIETF}
+	s.setKey(key)
+	return s, nil
+}
+
+// NewWithAEAD returns a Signer using the given AEAD. The AEAD's nonce size
+// selects the token version: 24-byte nonces produce Version tokens, and
+// 12-byte nonces produce VersionGCM tokens. Other nonce sizes return
+// ErrNonceLen.
+func NewWithAEAD(aead cipher.AEAD) (*Signer, error) {
+	switch aead.NonceSize() {
+	case NonceSize:
+		return &Signer{aead: aead, version: Version}, nil
+	case gcmNonceSize:
+		return &Signer{aead: aead, version: VersionGCM}, nil
+	}
+	return nil, ErrNonceLen
 }
 
-// Signer can Sign and Verify Tokens
+// NewWithFormat is like NewWithAEAD, but the Signer uses a version added
+// by RegisterFormat. It returns ErrVersion if the version is unregistered
+// or has a key id, and ErrNonceLen if the AEAD's nonce size differs from
+// the format's. If the format has its own Parse, the Signer only verifies:
+// Sign and the other signing methods return ErrVersion.
+func NewWithFormat(aead cipher.AEAD, version byte) (*Signer, error) {
+	f := format(version)
+	if f == nil || f.keyID {
+		return nil, ErrVersion
+	}
+	if aead.NonceSize() != f.nonceSize {
+		return nil, ErrNonceLen
+	}
+	return &Signer{aead: aead, version: version}, nil
+}
+
+// NewWithKeyID is like New, but the Signer embeds id in the header of every
+// token it signs (as version VersionKeyID). A KeyedVerifier uses the id to
+// select the verification key without trying each one.
+func NewWithKeyID(key []byte, id byte) (*Signer, error) {
+	s, err := New(key)
+	if err != nil {
+		return nil, err
+	}
+	s.version, s.id = VersionKeyID, id
+	return s, nil
+}
+
+// Signer can Sign and Verify Tokens. It is safe for concurrent use by
+// multiple goroutines, except for SignReuse and Reset. Its exported fields
+// are optional settings, and must not be modified once the Signer is in
+// use.
 type Signer struct {
-	aead cipher.AEAD
+	count uint64 // tokens signed; first, for 64-bit alignment on 32-bit platforms
+
+	// Guard, if not nil, records caller-supplied nonces and causes Sign to
+	// return ErrNonceReused when one is used again
+	Guard *NonceGuard
+
+	// StrictNonce, if true, also records the nonces Sign generates in
+	// Guard, as a defense against a broken randomness source. A generated
+	// nonce already in Guard is drawn again, up to three times, before
+	// Sign returns ErrNonceReused. It has no effect if Guard
+	// is nil. Guard's memory use grows with its size, about 100 bytes per
+	// nonce, and every Sign then takes Guard's lock.
+	StrictNonce bool
+
+	// Rand, if not nil, is the source of nonces generated by Sign.
+	// Otherwise, crypto/rand.Reader is used.
+	Rand io.Reader
+
+	// MaxMsgSize is the largest msg the Signer will sign, or that a token
+	// it verifies may contain. Larger inputs return ErrTooLong before any
+	// encryption or decryption. If zero, DefaultMaxMsgSize is used.
+	MaxMsgSize int
+
+	// Clock, if not nil, tells the time for expiry checks. Otherwise, the
+	// system clock is used.
+	Clock Clock
+
+	// ContextAAD, if not nil, extracts the values SignCtx and VerifyCtx bind
+	// a token to, such as a trace or tenant ID, from a context. Its result
+	// is authenticated as associated data, so it must encode the values
+	// unambiguously, and identically when signing and verifying.
+	ContextAAD func(ctx context.Context) []byte
+
+	// OnSign, if not nil, is called with the length of each token signed,
+	// after it is signed
+	OnSign func(tokenLen int)
+
+	// OnVerifyFail, if not nil, is called with the error each time a token
+	// fails to verify
+	OnVerifyFail func(err error)
+
+	aead    cipher.AEAD
+	version byte
+	id      byte
+	key     []byte
+	siv     []byte // key deriving nonces for SignDeterministic
+	jwt     []byte // HS256 key of SignJWT
+	closed  int32
+	buf     []byte      // reused by SignReuse
+	tagLen  int         // length of truncated tags, or zero
+	prev    cipher.AEAD // previous key, tried by Verify after aead
+}
+
+// Close zeroes the Signer's copy of its key and sub-keys. Afterwards, Sign
+// and Verify return ErrClosed.
+//
+// Zeroing is best-effort: the garbage collector may have copied the key
+// elsewhere, and the underlying AEAD keeps its own expanded key state,
+// which is released only once the Signer is unreachable. It still narrows
+// the window in which a heap or core dump of a long-lived process leaks
+// the key. Close must not be called concurrently with other methods.
+func (s *Signer) Close() {
+	atomic.StoreInt32(&s.closed, 1)
+	zero(s.key)
+	zero(s.siv)
+	zero(s.jwt)
+}
+
+// Clone returns a copy of s with the same key and configuration, sharing
+// its AEAD but none of its buffers, such as the one used by SignReuse. The
+// copy has its own copy of the key, so closing one does not close the
+// other. Clone is cheaper than New, as the AEAD is not set up again.
+func (s *Signer) Clone() *Signer {
+	c := &Signer{
+		Guard:        s.Guard,
+		StrictNonce:  s.StrictNonce,
+		Rand:         s.Rand,
+		MaxMsgSize:   s.MaxMsgSize,
+		Clock:        s.Clock,
+		ContextAAD:   s.ContextAAD,
+		OnSign:       s.OnSign,
+		OnVerifyFail: s.OnVerifyFail,
+		aead:         s.aead,
+		version:      s.version,
+		id:           s.id,
+		closed:       atomic.LoadInt32(&s.closed),
+		tagLen:       s.tagLen,
+		prev:         s.prev,
+	}
+	c.key = append([]byte(nil), s.key...)
+	c.siv = append([]byte(nil), s.siv...)
+	c.jwt = append([]byte(nil), s.jwt...)
+	return c
+}
+
+// WithKey replaces the key of s, keeping its version, key id, and
+// settings such as Rand, MaxMsgSize, and the hooks. Tokens signed
+// afterwards verify only with the new key. The old key is zeroed, as by
+// Close. It returns ErrKeyLen unless len(key) == 32, and ErrNoKey for a
+// Signer created with NewWithAEAD, whose AEAD can not be rebuilt.
+//
+// WithKey must not be called concurrently with other methods. To rotate the
+// key of a Signer shared by several goroutines, use a RotatingSigner or a
+// Keyring instead.
+func (s *Signer) WithKey(key []byte) error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+	if s.key == nil {
+		return ErrNoKey
+	}
+	var n *Signer
+	var err error
+	switch s.version {
+	case VersionGCM:
+		n, err = NewAESGCM(key)
+	case VersionIETF:
+		n, err = NewStandard(key)
+	default:
+		n, err = New(key)
+	}
+	if err != nil {
+		return err
+	}
+	zero(s.key)
+	zero(s.siv)
+	zero(s.jwt)
+	s.aead, s.key, s.siv, s.jwt = n.aead, n.key, n.siv, n.jwt
+	atomic.StoreUint64(&s.count, 0)
+	return nil
+}
+
+// setKey stores a copy of key and derives the sub-keys from it
+func (s *Signer) setKey(key []byte) {
+	s.key = append([]byte(nil), key...)
+	s.siv = subkey(key, sivPurpose)
+	s.jwt = jwtSubkey(key)
+}
 
-	// temporaries
-	n int
-	p [hdrSize]byte
+func (s *Signer) isClosed() bool {
+	return atomic.LoadInt32(&s.closed) != 0
+}
+
+func zero(p []byte) {
+	for i := range p {
+		p[i] = 0
+	}
 }
 
 // Sign generates a token from the given message and nonce. If the nonce
-// is nil, it is generated automatically using a CSPRNG (crypto/rand.Read).
+// is nil, it is generated automatically using a CSPRNG (crypto/rand.Read),
+// or read from the Signer's Rand if set.
 //
 // Most implementations will want to call Sign with a nil nonce. The option
 // to pass a nonce is provided for the use-case of regenerating a token
 // determinstically.
 //
-// You should never reuse the same nonce with a different msg or key.
+// You should never reuse the same nonce with a different msg or key. A
+// non-nil nonce must be exactly s.NonceSize() bytes, otherwise ErrNonceLen is
+// returned, and must not overlap msg, otherwise ErrOverlap is returned.
+//
+// The token never shares memory with msg or nonce, so the caller may reuse
+// them as soon as Sign returns.
 func (s *Signer) Sign(msg []byte, nonce []byte) (t Token, err error) {
-	if nonce == nil {
-		if nonce, err = mknonce(); err != nil {
-			return nil, err
-		}
+	return s.SignWithAAD(msg, nil, nonce)
+}
+
+// SignN is like Sign with a nil nonce, but also returns the generated
+// nonce. Passing it back to Sign with the same msg regenerates the token.
+func (s *Signer) SignN(msg []byte) (t Token, nonce []byte, err error) {
+	if len(msg) > s.maxMsgSize() {
+		return nil, nil, ErrTooLong
+	}
+	if nonce, err = s.nonce(nil); err != nil {
+		return nil, nil, err
+	}
+	if t, err = s.sign(msg, nil, nonce, ext{}); err != nil {
+		return nil, nil, err
+	}
+	return t, nonce, nil
+}
+
+// SignWithAAD is like Sign, but also authenticates the additional data aad,
+// binding the token to a context such as a user ID or URL path. The aad is
+// not stored in the token; the same aad must be passed to VerifyWithAAD.
+func (s *Signer) SignWithAAD(msg, aad, nonce []byte) (t Token, err error) {
+	if len(msg) > s.maxMsgSize() {
+		return nil, ErrTooLong
+	}
+	if anyOverlap(nonce, msg) {
+		return nil, ErrOverlap
+	}
+	if nonce, err = s.nonce(nonce); err != nil {
+		return nil, err
+	}
+	return s.sign(msg, aad, nonce, ext{})
+}
+
+// SignTo is like Sign, but appends the token to dst and returns the updated
+// slice, following the convention of cipher.AEAD's Seal. If dst has enough
+// spare capacity and a nonce is given, no allocation is made. The token is s.TokenSize(len(msg))
+// bytes long: the header, followed by len(msg) bytes of ciphertext and
+// s.Overhead() bytes of tag.
+//
+// The spare capacity of dst must not overlap msg or nonce, otherwise
+// ErrOverlap is returned.
+func (s *Signer) SignTo(dst, msg, nonce []byte) (t Token, err error) {
+	if len(msg) > s.maxMsgSize() {
+		return nil, ErrTooLong
+	}
+	if spare := dst[len(dst):cap(dst)]; anyOverlap(spare, msg) || anyOverlap(spare, nonce) || anyOverlap(nonce, msg) {
+		return nil, ErrOverlap
+	}
+	if nonce, err = s.nonce(nonce); err != nil {
+		return nil, err
+	}
+	if err := s.canSign(); err != nil {
+		return nil, err
+	}
+	n := len(dst)
+	dst = s.header(dst, nonce, ext{})
+	sealed, err := sealAEAD(s.aead, dst, nonce, msg, dst[n:])
+	if err != nil {
+		return nil, err
+	}
+	t = s.truncate(sealed)
+	s.signed(len(t) - n)
+	return t, nil
+}
+
+// SignReuse is like Sign, but writes the token into a buffer owned by the
+// Signer, which is reused by every call to avoid allocating.
+//
+// The returned token is only valid until the next call to SignReuse or
+// Reset: both overwrite it in place. Callers that retain the token must
+// copy it. SignReuse is not safe for concurrent use, even with itself.
+func (s *Signer) SignReuse(msg, nonce []byte) (t Token, err error) {
+	t, err = s.SignTo(s.buf[:0], msg, nonce)
+	if err != nil {
+		return nil, err
 	}
-	return s.sign(msg, nonce), nil
+	s.buf = t
+	return t, nil
+}
+
+// Reset empties the buffer used by SignReuse, retaining its storage,
+// like bytes.Buffer's Reset. Tokens returned by SignReuse are invalid
+// afterwards.
+func (s *Signer) Reset() {
+	s.buf = s.buf[:0]
 }
 
 // Verify verifies and decrypts the token contents, returning the
 // decrypted msg if and only if the token is authentic with respect
-// to the Signer's key.
+// to the Signer's key. If the token fails authentication, the error
+// matches ErrUnverified with errors.Is; malformed tokens return errors
+// such as ErrShort or ErrVersion instead. Tokens holding a msg longer than
+// MaxMsgSize return ErrTooLong before anything is decrypted or allocated,
+// so Verify is safe to call on untrusted input of any size.
+//
+// Empty messages are supported: signing a nil or empty msg produces a
+// token with only a header and tag, and verifying it returns a non-nil,
+// empty msg. The msg is nil only if err is not.
 func (s *Signer) Verify(c Token) (msg []byte, err error) {
-	if len(c) < hdrSize {
-		return nil, ErrShort
+	return s.VerifyWithAAD(c, nil)
+}
+
+// SignString is like Sign, but signs a string and returns the token encoded
+// by Token.Encode
+func (s *Signer) SignString(msg string, nonce []byte) (string, error) {
+	t, err := s.Sign([]byte(msg), nonce)
+	if err != nil {
+		return "", err
+	}
+	return t.Encode(), nil
+}
+
+// VerifyString is like Verify, but verifies a token encoded by Token.Encode
+// and returns the msg as a string. Malformed input returns an error wrapping
+// ErrEncoding.
+func (s *Signer) VerifyString(token string) (string, error) {
+	t, err := ParseToken(token)
+	if err != nil {
+		return "", err
+	}
+	msg, err := s.Verify(t)
+	if err != nil {
+		return "", err
+	}
+	return string(msg), nil
+}
+
+// VerifyWithAAD is like Verify, but the token must have been signed with
+// SignWithAAD and the same aad.
+func (s *Signer) VerifyWithAAD(c Token, aad []byte) (msg []byte, err error) {
+	return s.open(nil, c, aad)
+}
+
+// VerifyInto is like Verify, but appends the decrypted msg to dst and
+// returns the updated slice, following the convention of cipher.AEAD's Open.
+// Errors are the same as those returned by Verify.
+//
+// To decrypt in place, dst may be the token's ciphertext with zero length,
+// i.e. c[n:n] where n = s.TokenSize(0) - s.Overhead() is the header length.
+// This overwrites the token. Otherwise, the spare capacity of dst must not
+// overlap the token at all.
+func (s *Signer) VerifyInto(dst []byte, c Token) (msg []byte, err error) {
+	return s.open(dst, c, nil)
+}
+
+// Result is the outcome of a successful VerifyMeta
+type Result struct {
+	Msg     []byte // the decrypted msg
+	Nonce   []byte // the token's nonce
+	Version byte   // the token's version
+}
+
+// VerifyMeta is like Verify, but also returns the token's nonce and version.
+// These are returned only if the token is authentic.
+func (s *Signer) VerifyMeta(c Token) (Result, error) {
+	msg, err := s.Verify(c)
+	if err != nil {
+		return Result{}, err
+	}
+	h, _ := c.parse()
+	return Result{
+		Msg:     msg,
+		Nonce:   append([]byte(nil), h.nonce...),
+		Version: h.version,
+	}, nil
+}
+
+// VerifyWithNonce is like Verify, but also returns a copy of the token's
+// nonce, so that the same msg can be signed again with Sign(msg, nonce) to
+// reproduce the token exactly. The nonce is returned only if the token is
+// authentic.
+//
+// Signing a different msg with the recovered nonce reuses it, which breaks
+// the confidentiality of both msgs and lets the key be forged with: reuse
+// it only to replay the exact same msg.
+func (s *Signer) VerifyWithNonce(t Token) (msg, nonce []byte, err error) {
+	r, err := s.VerifyMeta(t)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r.Msg, r.Nonce, nil
+}
+
+// open verifies c and appends the decrypted msg to dst
+func (s *Signer) open(dst []byte, c Token, aad []byte) ([]byte, error) {
+	msg, err := s.openToken(dst, c, aad)
+	if err != nil {
+		return nil, s.fail(err)
+	}
+	return msg, nil
+}
+
+// fail calls the OnVerifyFail hook, if any, and returns err
+func (s *Signer) fail(err error) error {
+	if s.OnVerifyFail != nil {
+		s.OnVerifyFail(err)
+	}
+	return err
+}
+
+func (s *Signer) openToken(dst []byte, c Token, aad []byte) ([]byte, error) {
+	h, msg, err := s.openRaw(dst, c, aad)
+	if err != nil {
+		return nil, err
+	}
+	return s.decoded(dst, h, msg)
+}
+
+// decoded reverses any encoding of the plaintext p sealed with header h
+func (s *Signer) decoded(dst []byte, h header, p []byte) (msg []byte, err error) {
+	msg = p
+	if h.flags&encodingFlags != 0 {
+		if msg, err = s.decode(dst, p, h.flags); err != nil {
+			return nil, err
+		}
+	}
+	if msg == nil {
+		// an empty msg is distinguishable from a failed verification
+		msg = []byte{}
+	}
+	return msg, nil
+}
+
+// openRaw verifies c and returns its header and sealed plaintext, without
+// reversing any encoding applied to the msg. The plaintext is appended to
+// dst, unless it is encoded, in which case dst is not used.
+func (s *Signer) openRaw(dst []byte, c Token, aad []byte) (h header, p []byte, err error) {
+	if s.isClosed() {
+		return h, nil, ErrClosed
+	}
+	if h, err = s.parse(c); err != nil {
+		return h, nil, err
+	}
+	if err = s.validateLayout(c, h); err != nil {
+		return h, nil, err
+	}
+	p, err = s.unseal(dst, h, c[:h.n], c[h.n:], aad)
+	return h, p, err
+}
+
+// unseal opens the AEAD output ae sealed with header h, whose encoding is
+// hdr, like openRaw
+func (s *Signer) unseal(dst []byte, h header, hdr, ae, aad []byte) (p []byte, err error) {
+	if len(ae) < s.Overhead() {
+		// too short to hold the tag, so Open can only fail
+		return nil, ErrTruncatedTag
+	}
+	if len(ae)-s.Overhead() > s.maxMsgSize() {
+		return nil, ErrTooLong
+	}
+	if h.flags&encodingFlags != 0 {
+		dst = nil
+	}
+	if s.prev != nil && anyOverlap(dst[len(dst):cap(dst)], ae) {
+		// a failed Open clears its output, which would destroy ae before
+		// the previous key could try it
+		dst = nil
+	}
+	if s.tagLen != 0 {
+		p, err = s.openTruncated(dst, h.nonce, ae, withAAD(hdr, aad))
+	} else {
+		p, err = openAEAD(s.aead, dst, h.nonce, ae, withAAD(hdr, aad))
+		if err != nil && !errors.Is(err, ErrInternal) && s.prev != nil {
+			p, err = openAEAD(s.prev, dst, h.nonce, ae, withAAD(hdr, aad))
+		}
+	}
+	if errors.Is(err, ErrInternal) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, unverified{err}
+	}
+	return p, nil
+}
+
+// Check is like Verify, but only returns the error, such as ErrShort,
+// ErrVersion, or one matching ErrUnverified. The token is still decrypted,
+// but into a pooled buffer that is cleared and reused, so that checks do
+// not allocate for the msg.
+func (s *Signer) Check(t Token) error {
+	msg, release, err := s.VerifyPooled(t)
+	if err != nil {
+		return err
+	}
+	zero(msg)
+	release()
+	return nil
+}
+
+// VerifyParts is like Verify, for a token whose header and the rest, the
+// ciphertext and tag, are stored separately. The header must be exactly as
+// long as the token's header, including its optional fields, otherwise
+// ErrShort or ErrEncoding is returned.
+func (s *Signer) VerifyParts(header, ciphertext []byte) ([]byte, error) {
+	msg, err := s.openParts(header, ciphertext)
+	if err != nil {
+		return nil, s.fail(err)
+	}
+	return msg, nil
+}
+
+func (s *Signer) openParts(hdr, ae []byte) ([]byte, error) {
+	if s.isClosed() {
+		return nil, ErrClosed
+	}
+	h, err := s.parse(hdr)
+	if err != nil {
+		return nil, err
 	}
-	n := hdrSize
-	ae, ad := c[n:], c[:n]
-	nonce := ad[1:]
-	return s.aead.Open(nil, nonce, ae, ad)
+	if h.n != len(hdr) {
+		return nil, ErrEncoding
+	}
+	p, err := s.unseal(nil, h, hdr, ae, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.decoded(nil, h, p)
+}
+
+// decode reverses the encodings described by flags, in the opposite order
+// to which they were applied, and appends the msg to dst
+func (s *Signer) decode(dst, p []byte, flags byte) (msg []byte, err error) {
+	if flags&flagPadded != 0 {
+		if p, err = unpad(p); err != nil {
+			return nil, err
+		}
+	}
+	if flags&flagCompressed != 0 {
+		return s.inflate(dst, p)
+	}
+	return append(dst, p...), nil
+}
+
+// AEAD returns the AEAD underlying s, for interoperating with code that
+// accepts a cipher.AEAD.
+//
+// This is unsafe: the caller must supply unique nonces, none of which may
+// also be used by s, and the AEAD's output has no header, so it is not a
+// token and s can not verify it. The AEAD's tag is never truncated, and
+// closing s does not affect it.
+func (s *Signer) AEAD() cipher.AEAD {
+	return s.aead
+}
+
+// NonceSize returns the size of the nonces used by s
+func (s *Signer) NonceSize() int {
+	return s.aead.NonceSize()
+}
+
+// nonce validates a caller-supplied nonce, or generates one if it is nil
+func (s *Signer) nonce(nonce []byte) ([]byte, error) {
+	if s.isClosed() {
+		return nil, ErrClosed
+	}
+	if nonce == nil {
+		return s.mknonce()
+	}
+	if len(nonce) != s.NonceSize() {
+		return nil, ErrNonceLen
+	}
+	if s.Guard != nil {
+		if err := s.Guard.Use(nonce); err != nil {
+			return nil, err
+		}
+	}
+	return nonce, nil
+}
+
+func (s *Signer) maxMsgSize() int {
+	if s.MaxMsgSize == 0 {
+		return DefaultMaxMsgSize
+	}
+	return s.MaxMsgSize
+}
+
+// Overhead returns the length of the AEAD tag appended to each token,
+// after any truncation
+func (s *Signer) Overhead() int {
+	if s.tagLen != 0 {
+		return s.tagLen
+	}
+	return s.aead.Overhead()
+}
+
+// TokenSize returns the length of a token created by s from a message of
+// msgLen bytes
+func (s *Signer) TokenSize(msgLen int) int {
+	return s.hdrLen() + msgLen + s.Overhead()
+}
+
+// FixedTokenSize returns the exact length of a token Sign creates from a
+// msg of msgLen bytes, for embedding tokens in fixed-width fields. It is
+// the same as TokenSize; tokens with optional fields, such as from
+// SignWithTTL or SignOpts, are longer.
+func (s *Signer) FixedTokenSize(msgLen int) int {
+	return s.TokenSize(msgLen)
+}
+
+// SignFixed is like Sign, but returns ErrTokenSize, without signing, unless
+// the token would be exactly wantLen bytes long. It guards fixed-width
+// records against a change of version or tag size altering the length of
+// their tokens.
+func (s *Signer) SignFixed(msg, nonce []byte, wantLen int) (Token, error) {
+	if s.FixedTokenSize(len(msg)) != wantLen {
+		return nil, ErrTokenSize
+	}
+	return s.Sign(msg, nonce)
 }
 
-func mknonce() ([]byte, error) {
-	p := make([]byte, NonceSize)
-	_, err := rand.Read(p)
-	return p, err
+// PlaintextLen returns the length of the plaintext sealed in t, without
+// verifying it, so that a caller can size the destination for VerifyInto.
+// For compressed or padded tokens, this is an upper bound: the msg
+// returned by Verify is shorter. It returns ErrShort if t is too short to
+// hold a header and tag.
+func (s *Signer) PlaintextLen(t Token) (int, error) {
+	h, err := s.parse(t)
+	if err != nil {
+		return 0, err
+	}
+	n := len(t) - h.n - s.Overhead()
+	if n < 0 {
+		return 0, ErrShort
+	}
+	return n, nil
 }
 
-func (s Signer) sign(msg []byte, nonce []byte) []byte {
-	s.put([]byte{Version})
-	s.put(nonce)
-	return append(s.p[:s.n], s.aead.Seal(nil, nonce, msg, s.p[:s.n])...)
+// mknonce generates a nonce. A short read from the source is an error.
+func (s *Signer) mknonce() ([]byte, error) {
+	p := make([]byte, s.NonceSize())
+	for i := 0; ; i++ {
+		if _, err := io.ReadFull(s.rand(), p); err != nil {
+			return nil, err
+		}
+		if !s.StrictNonce || s.Guard == nil {
+			return p, nil
+		}
+		err := s.Guard.Use(p)
+		if err == nil {
+			return p, nil
+		}
+		if i == strictNonceRetries {
+			return nil, err
+		}
+	}
 }
 
-func (s *Signer) put(p []byte) {
-	s.n += copy(s.p[s.n:], p)
+// strictNonceRetries is the number of times a duplicate nonce is drawn
+// again in StrictNonce mode
+const strictNonceRetries = 3
+
+func (s *Signer) rand() io.Reader {
+	if s.Rand == nil {
+		return rand.Reader
+	}
+	return s.Rand
+}
+
+// sign seals msg into a new token. The token is allocated once, at its
+// final size, and the header is built on the stack first to learn its size.
+func (s *Signer) sign(msg, aad, nonce []byte, e ext) (Token, error) {
+	if err := s.canSign(); err != nil {
+		return nil, err
+	}
+	var p [maxHdrSize]byte
+	hdr := s.header(p[:0], nonce, e)
+	t := make([]byte, len(hdr), len(hdr)+len(msg)+s.aead.Overhead())
+	copy(t, hdr)
+	t, err := sealAEAD(s.aead, t, nonce, msg, withAAD(t, aad))
+	if err != nil {
+		return nil, err
+	}
+	t = s.truncate(t)
+	s.signed(len(t))
+	return t, nil
+}
+
+// canSign returns ErrVersion if s's version has a Parse from
+// RegisterFormat, whose layout s can not write
+func (s *Signer) canSign() error {
+	if format(s.version).custom {
+		return ErrVersion
+	}
+	return nil
+}
+
+// signed counts a token of n bytes signed by s, and calls the OnSign hook
+func (s *Signer) signed(n int) {
+	atomic.AddUint64(&s.count, 1)
+	if s.OnSign != nil {
+		s.OnSign(n)
+	}
 }
@@ -1,9 +1,30 @@
 package signer_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/as/signer"
+	"github.com/as/signer/signertest"
 )
 
 func TestBasicSignVerify(t *testing.T) {
@@ -91,3 +112,2854 @@ func ck(t *testing.T, ctx string, err error) {
 		t.Fatalf(ctx, err)
 	}
 }
+
+func TestTruncated(t *testing.T) {
+	key := vectorTab[0].key[:]
+	full, err := signer.New(key)
+	ck(t, "new", err)
+	for _, bits := range []int{64, 96, 120, 128} {
+		s, err := signer.NewTruncated(key, bits)
+		ck(t, "new truncated", err)
+		tok, err := s.Sign([]byte("hello world"), nil)
+		ck(t, "sign", err)
+		if len(tok) != s.TokenSize(len("hello world")) {
+			t.Fatalf("%d bits: token size: have %d, want %d", bits, len(tok), s.TokenSize(len("hello world")))
+		}
+		p, err := s.Verify(tok)
+		ck(t, "verify", err)
+		if string(p) != "hello world" {
+			t.Fatalf("%d bits: have %q, want %q", bits, p, "hello world")
+		}
+		if _, err := full.Verify(tok); (err == nil) != (bits == 128) {
+			t.Fatalf("%d bits: full signer verify: %v", bits, err)
+		}
+		tok[len(tok)-1] ^= 1
+		if _, err := s.Verify(tok); !errors.Is(err, signer.ErrUnverified) {
+			t.Fatalf("%d bits: modified tag: have %v, want %v", bits, err, signer.ErrUnverified)
+		}
+	}
+	for _, bits := range []int{0, 56, 60, 136} {
+		if _, err := signer.NewTruncated(key, bits); err != signer.ErrTagSize {
+			t.Fatalf("%d bits: have %v, want %v", bits, err, signer.ErrTagSize)
+		}
+	}
+}
+
+func TestSealMulti(t *testing.T) {
+	var recipients []*signer.Signer
+	for i := 0; i < 4; i++ {
+		key := make([]byte, signer.KeySize)
+		key[0] = byte(i)
+		s, err := signer.New(key)
+		ck(t, "new", err)
+		recipients = append(recipients, s)
+	}
+	outsider := recipients[3]
+	recipients = recipients[:3]
+	tok, err := signer.SealMulti([]byte("hello world"), recipients)
+	ck(t, "seal", err)
+	for i, s := range recipients {
+		p, err := s.OpenMulti(tok)
+		ck(t, "open", err)
+		if string(p) != "hello world" {
+			t.Fatalf("recipient %d: have %q, want %q", i, p, "hello world")
+		}
+	}
+	if _, err := outsider.OpenMulti(tok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("outsider: have %v, want %v", err, signer.ErrUnverified)
+	}
+
+	// the last recipient skips the others' keys, of another version too
+	gcm, err := signer.NewAESGCM(bytes.Repeat([]byte{9}, signer.KeySize))
+	ck(t, "new gcm", err)
+	last := recipients[2]
+	tok, err = signer.SealMulti([]byte("hello world"), []*signer.Signer{gcm, recipients[0], last})
+	ck(t, "seal", err)
+	fails := 0
+	last.OnVerifyFail = func(error) { fails++ }
+	outsider.OnVerifyFail = func(error) { fails++ }
+	_, err = last.OpenMulti(tok)
+	ck(t, "open last", err)
+	if fails != 0 {
+		t.Fatalf("recipient: OnVerifyFail called %d times, want 0", fails)
+	}
+	if _, err := outsider.OpenMulti(tok); !errors.Is(err, signer.ErrUnverified) || fails != 1 {
+		t.Fatalf("outsider: have %v with %d OnVerifyFail calls, want %v with 1", err, fails, signer.ErrUnverified)
+	}
+	last.Close()
+	if _, err := last.OpenMulti(tok); err != signer.ErrClosed {
+		t.Fatalf("closed: have %v, want %v", err, signer.ErrClosed)
+	}
+}
+
+func TestShort(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	tok := signer.Token(vectorTab[0].binary)
+	for _, n := range []int{0, 1, 1 + 24, len(tok) - 1} {
+		if _, err := s.Verify(tok[:n]); !errors.Is(err, signer.ErrShort) {
+			t.Fatalf("%d bytes: have %v, want %v", n, err, signer.ErrShort)
+		}
+	}
+}
+
+func TestCounterNonce(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	s.Rand, err = signer.NewCounterNonce()
+	ck(t, "counter", err)
+	var last []byte
+	for i := 0; i < 1000; i++ {
+		tok, err := s.Sign([]byte("hello world"), nil)
+		ck(t, "sign", err)
+		nonce, err := tok.Nonce()
+		ck(t, "nonce", err)
+		if last != nil && bytes.Compare(nonce, last) <= 0 {
+			t.Fatalf("sign %d: nonce %x does not follow %x", i, nonce, last)
+		}
+		last = nonce
+	}
+}
+
+func TestAEAD(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	z := vectorTab[0]
+	aead := s.AEAD()
+	tok := signer.Token(z.binary)
+	p, err := aead.Open(nil, z.nonce[:], tok[1+24:], tok[:1+24])
+	ck(t, "open", err)
+	if string(p) != z.input {
+		t.Fatalf("have %q, want %q", p, z.input)
+	}
+}
+
+func TestVerifyTooLong(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	tok := make(signer.Token, 100<<20)
+	copy(tok, vectorTab[0].binary)
+	allocs := testing.AllocsPerRun(10, func() {
+		if _, err := s.Verify(tok); err != signer.ErrTooLong {
+			t.Fatalf("have %v, want %v", err, signer.ErrTooLong)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("rejecting a long token allocated %v times", allocs)
+	}
+}
+
+func TestClone(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(c *signer.Signer) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				tok, err := c.SignReuse([]byte("hello world"), nil)
+				if err != nil {
+					t.Errorf("sign: %v", err)
+					return
+				}
+				if _, err := s.Verify(tok); err != nil {
+					t.Errorf("verify: %v", err)
+					return
+				}
+			}
+		}(s.Clone())
+	}
+	wg.Wait()
+
+	c := s.Clone()
+	c.Close()
+	if _, err := s.Sign(nil, nil); err != nil {
+		t.Fatalf("closing a clone closed the original: %v", err)
+	}
+}
+
+func TestPlaintextLen(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	tok, err := s.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	n, err := s.PlaintextLen(tok)
+	ck(t, "plaintext len", err)
+	if n != len("hello world") {
+		t.Fatalf("have %d, want %d", n, len("hello world"))
+	}
+	if _, err := s.PlaintextLen(tok[:len(tok)-len("hello world")-1]); err != signer.ErrShort {
+		t.Fatalf("short token: have %v, want %v", err, signer.ErrShort)
+	}
+}
+
+func TestRefreshNoncePrefix(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	ck(t, "refresh default", s.RefreshNoncePrefix())
+	s.Rand, err = signer.NewCounterNonce()
+	ck(t, "counter", err)
+	seen := map[string]bool{}
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 100; i++ {
+			tok, err := s.Sign(nil, nil)
+			ck(t, "sign", err)
+			nonce, err := tok.Nonce()
+			ck(t, "nonce", err)
+			if seen[string(nonce)] {
+				t.Fatalf("round %d, sign %d: nonce %x reused", round, i, nonce)
+			}
+			seen[string(nonce)] = true
+		}
+		ck(t, "refresh", s.RefreshNoncePrefix())
+	}
+}
+
+func TestVerifyBatch(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	var msgs [][]byte
+	for i := 0; i < 50; i++ {
+		msgs = append(msgs, []byte{byte(i)})
+	}
+	tokens, err := s.SignBatch(msgs)
+	ck(t, "sign", err)
+	for i := 0; i < len(tokens); i += 3 {
+		tokens[i][len(tokens[i])-1] ^= 1
+	}
+	have, errs := s.VerifyBatch(tokens, 4)
+	for i := range tokens {
+		if i%3 == 0 {
+			if !errors.Is(errs[i], signer.ErrUnverified) {
+				t.Fatalf("token %d: have %v, want %v", i, errs[i], signer.ErrUnverified)
+			}
+			continue
+		}
+		ck(t, "verify", errs[i])
+		if !bytes.Equal(have[i], msgs[i]) {
+			t.Fatalf("token %d: have %q, want %q", i, have[i], msgs[i])
+		}
+	}
+}
+
+func TestVerifyParts(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	a, err := s.Sign([]byte("hello"), nil)
+	ck(t, "sign", err)
+	b, err := s.Sign([]byte("world"), nil)
+	ck(t, "sign", err)
+	const n = 1 + 24
+	p, err := s.VerifyParts(a[:n], a[n:])
+	ck(t, "verify", err)
+	if string(p) != "hello" {
+		t.Fatalf("have %q, want %q", p, "hello")
+	}
+	if _, err := s.VerifyParts(a[:n], b[n:]); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("mismatched parts: have %v, want %v", err, signer.ErrUnverified)
+	}
+	if _, err := s.VerifyParts(a[:n+1], a[n+1:]); err != signer.ErrEncoding {
+		t.Fatalf("long header: have %v, want %v", err, signer.ErrEncoding)
+	}
+}
+
+func TestSignUniform(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	random := make([]byte, 200)
+	_, err = rand.Read(random)
+	ck(t, "rand", err)
+	const size = 256
+	var tokLen int
+	for _, msg := range [][]byte{
+		nil,
+		[]byte("hello world"),
+		bytes.Repeat([]byte("a"), 10000),
+		random,
+	} {
+		tok, err := s.SignUniform(msg, nil, size)
+		ck(t, "sign", err)
+		if n, _ := s.PlaintextLen(tok); n != size {
+			t.Fatalf("%d byte msg: sealed size: have %d, want %d", len(msg), n, size)
+		}
+		if tokLen != 0 && len(tok) != tokLen {
+			t.Fatalf("%d byte msg: token size: have %d, want %d", len(msg), len(tok), tokLen)
+		}
+		tokLen = len(tok)
+		p, err := s.Verify(tok)
+		ck(t, "verify", err)
+		if !bytes.Equal(p, msg) {
+			t.Fatalf("%d byte msg: have %q, want %q", len(msg), p, msg)
+		}
+	}
+	random = make([]byte, size)
+	_, err = rand.Read(random)
+	ck(t, "rand", err)
+	if _, err := s.SignUniform(random, nil, size); err != signer.ErrTooLong {
+		t.Fatalf("incompressible msg: have %v, want %v", err, signer.ErrTooLong)
+	}
+}
+
+func TestFormats(t *testing.T) {
+	key := vectorTab[0].key[:]
+	x, err := signer.New(key)
+	ck(t, "new", err)
+	gcm, err := signer.NewAESGCM(key)
+	ck(t, "new gcm", err)
+	for _, tc := range []struct {
+		s       *signer.Signer
+		version byte
+		nonce   int
+	}{
+		{x, signer.Version, 24},
+		{gcm, signer.VersionGCM, 12},
+	} {
+		tok, err := tc.s.Sign([]byte("hello world"), nil)
+		ck(t, "sign", err)
+		v, nonce, _, err := signer.Split(tok)
+		ck(t, "split", err)
+		if v != tc.version || len(nonce) != tc.nonce {
+			t.Fatalf("have version %q with %d byte nonce, want %q with %d", v, len(nonce), tc.version, tc.nonce)
+		}
+		_, err = tc.s.Verify(tok)
+		ck(t, "verify", err)
+		tok[0] = 'Z'
+		if _, err := tc.s.Verify(tok); err != signer.ErrVersion {
+			t.Fatalf("unknown version: have %v, want %v", err, signer.ErrVersion)
+		}
+	}
+}
+
+func TestKeyLen(t *testing.T) {
+	for _, n := range []int{0, 16, 31, 33} {
+		key := make([]byte, n)
+		for name, fn := range map[string]func([]byte) (*signer.Signer, error){
+			"New":         signer.New,
+			"NewAESGCM":   signer.NewAESGCM,
+			"NewStandard": signer.NewStandard,
+		} {
+			if _, err := fn(key); !errors.Is(err, signer.ErrKeyLen) {
+				t.Fatalf("%s: %d byte key: have %v, want %v", name, n, err, signer.ErrKeyLen)
+			}
+		}
+	}
+}
+
+func TestTokenValid(t *testing.T) {
+	tok := signer.Token(vectorTab[0].binary)
+	if !tok.Valid() {
+		t.Fatalf("well-known vector is not valid")
+	}
+	if tok[:len(tok)-1].Valid() {
+		t.Fatalf("short token is valid")
+	}
+	bad := append(signer.Token(nil), tok...)
+	bad[0] = 'Z'
+	if bad.Valid() {
+		t.Fatalf("token with unknown version is valid")
+	}
+}
+
+func TestDecryptReaderFailsFast(t *testing.T) {
+	const (
+		frames    = 10
+		bad       = 5
+		frameSize = signer.DefaultFrameSize
+		sealed    = 4 + frameSize + 16
+	)
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	var b bytes.Buffer
+	w, err := s.NewEncryptWriter(&b)
+	ck(t, "writer", err)
+	_, err = w.Write(bytes.Repeat([]byte("a"), frames*frameSize))
+	ck(t, "write", err)
+	ck(t, "close", w.Close())
+
+	stream := b.Bytes()
+	stream[1+24+bad*sealed+10] ^= 1
+	r, err := s.NewDecryptReader(bytes.NewReader(stream))
+	ck(t, "reader", err)
+	var n int
+	p := make([]byte, 1000)
+	for {
+		m, err := r.Read(p)
+		n += m
+		if err != nil {
+			if !errors.Is(err, signer.ErrUnverified) {
+				t.Fatalf("have %v, want %v", err, signer.ErrUnverified)
+			}
+			break
+		}
+	}
+	if n != bad*frameSize {
+		t.Fatalf("read %d bytes before the tampered frame, want %d", n, bad*frameSize)
+	}
+}
+
+func TestSignLabeled(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	tok, err := s.SignLabeled([]byte("eu-west"), []byte("hello world"), nil)
+	ck(t, "sign", err)
+	label, err := signer.Label(tok)
+	ck(t, "label", err)
+	if string(label) != "eu-west" {
+		t.Fatalf("label: have %q, want %q", label, "eu-west")
+	}
+	label, msg, err := s.VerifyLabeled(tok)
+	ck(t, "verify", err)
+	if string(label) != "eu-west" || string(msg) != "hello world" {
+		t.Fatalf("have %q, %q, want %q, %q", label, msg, "eu-west", "hello world")
+	}
+	i := bytes.Index(tok, []byte("eu-west"))
+	tok[i] = 'E'
+	if _, _, err := s.VerifyLabeled(tok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("tampered label: have %v, want %v", err, signer.ErrUnverified)
+	}
+}
+
+func TestStreamContext(t *testing.T) {
+	const frameSize = signer.DefaultFrameSize
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	var b bytes.Buffer
+	w, err := s.NewEncryptWriter(&b)
+	ck(t, "writer", err)
+	_, err = w.Write(bytes.Repeat([]byte("a"), 3*frameSize))
+	ck(t, "write", err)
+	ck(t, "close", w.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, err := s.NewDecryptReaderContext(ctx, &b)
+	ck(t, "reader", err)
+	_, err = io.ReadFull(r, make([]byte, frameSize))
+	ck(t, "read", err)
+	cancel()
+	if _, err := r.Read(make([]byte, 1)); err != context.Canceled {
+		t.Fatalf("read: have %v, want %v", err, context.Canceled)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	w, err = s.NewEncryptWriterContext(ctx, ioutil.Discard)
+	ck(t, "writer", err)
+	_, err = w.Write(make([]byte, frameSize+1))
+	ck(t, "write", err)
+	cancel()
+	if _, err := w.Write([]byte("a")); err != context.Canceled {
+		t.Fatalf("write: have %v, want %v", err, context.Canceled)
+	}
+	if err := w.Close(); err != context.Canceled {
+		t.Fatalf("close: have %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestVerifyPooled(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	for _, msg := range []string{"hello world", "", "bye"} {
+		tok, err := s.Sign([]byte(msg), nil)
+		ck(t, "sign", err)
+		p, release, err := s.VerifyPooled(tok)
+		ck(t, "verify", err)
+		if string(p) != msg {
+			t.Fatalf("have %q, want %q", p, msg)
+		}
+		release()
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	s, _ := signer.New(vectorTab[0].key[:])
+	tok, _ := s.Sign(make([]byte, 1024), nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.Verify(tok)
+	}
+}
+
+func BenchmarkVerifyPooled(b *testing.B) {
+	s, _ := signer.New(vectorTab[0].key[:])
+	tok, _ := s.Sign(make([]byte, 1024), nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, release, _ := s.VerifyPooled(tok)
+		release()
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	key := vectorTab[0].key[:]
+	for name, fn := range map[string]func([]byte) (*signer.Signer, error){
+		"NewChecked":  signer.NewChecked,
+		"NewAESGCM":   signer.NewAESGCM,
+		"NewStandard": signer.NewStandard,
+	} {
+		s, err := fn(key)
+		ck(t, "new", err)
+		if err := s.SelfTest(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestParseTokenVariants(t *testing.T) {
+	tok := signer.Token("\xfb\xff\xfe hello")
+	for _, enc := range []*base64.Encoding{
+		base64.RawURLEncoding,
+		base64.URLEncoding,
+		base64.RawStdEncoding,
+		base64.StdEncoding,
+	} {
+		s := enc.EncodeToString(tok)
+		have, err := signer.ParseToken(s)
+		ck(t, "parse", err)
+		if !bytes.Equal(have, tok) {
+			t.Fatalf("%q: have %q, want %q", s, have, tok)
+		}
+	}
+	for _, s := range []string{"a=b", "QQ-_+/", "QQAA====", "QQ=", "QQA=="} {
+		if _, err := signer.ParseToken(s); !errors.Is(err, signer.ErrEncoding) {
+			t.Fatalf("%q: have %v, want %v", s, err, signer.ErrEncoding)
+		}
+	}
+}
+
+func BenchmarkSign(b *testing.B) {
+	s, _ := signer.New(vectorTab[0].key[:])
+	for _, n := range []int{16, 64, 256} {
+		msg := make([]byte, n)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s.Sign(msg, nil)
+			}
+		})
+	}
+}
+
+func TestTokenGob(t *testing.T) {
+	type record struct {
+		ID    int
+		Token signer.Token
+	}
+	in := record{1, signer.Token(vectorTab[0].binary)}
+	var b bytes.Buffer
+	ck(t, "encode", gob.NewEncoder(&b).Encode(in))
+	var out record
+	ck(t, "decode", gob.NewDecoder(&b).Decode(&out))
+	if out.ID != in.ID || !bytes.Equal(out.Token, in.Token) {
+		t.Fatalf("have %+v, want %+v", out, in)
+	}
+	var tok signer.Token
+	if err := tok.UnmarshalBinary([]byte("A")); err != signer.ErrShort {
+		t.Fatalf("have %v, want %v", err, signer.ErrShort)
+	}
+	if err := tok.UnmarshalBinary([]byte(vectorTab[0].binary[:1+23])); err != signer.ErrShort {
+		t.Fatalf("short nonce: have %v, want %v", err, signer.ErrShort)
+	}
+
+	// the header of a format with 4 byte nonces is complete in 5 bytes
+	short, err := shortNonceSigner(t).Sign(nil, nil)
+	ck(t, "sign R", err)
+	short = short[:1+4]
+	ck(t, "unmarshal R", tok.UnmarshalBinary(short))
+	if !bytes.Equal(tok, short) {
+		t.Fatalf("have %x, want %x", tok, short)
+	}
+}
+
+func TestCounterNonceThreshold(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	c, err := signer.NewCounterNonce()
+	ck(t, "counter", err)
+	var warnings []uint64
+	c.Limit = 10
+	c.Threshold = 0.25
+	c.OnNonceThreshold = func(remaining uint64) { warnings = append(warnings, remaining) }
+	s.Rand = c
+	for i := 0; i < 10; i++ {
+		_, err := s.Sign(nil, nil)
+		ck(t, "sign", err)
+	}
+	if len(warnings) != 1 || warnings[0] != 2 {
+		t.Fatalf("warnings: have %v, want [2]", warnings)
+	}
+	if _, err := s.Sign(nil, nil); err != signer.ErrNonceExhausted {
+		t.Fatalf("have %v, want %v", err, signer.ErrNonceExhausted)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	key := vectorTab[0].key[:]
+	s, err := signer.New(key)
+	ck(t, "new", err)
+	tok, err := s.SignLabeled([]byte("label"), []byte("hello world"), nil)
+	ck(t, "sign", err)
+	fresh, err := s.Refresh(tok)
+	ck(t, "refresh", err)
+	label, p, err := s.VerifyLabeled(fresh)
+	ck(t, "verify", err)
+	if string(label) != "label" || string(p) != "hello world" {
+		t.Fatalf("have %q, %q, want %q, %q", label, p, "label", "hello world")
+	}
+	n0, _ := tok.Nonce()
+	n1, _ := fresh.Nonce()
+	if bytes.Equal(n0, n1) {
+		t.Fatalf("refreshed token has the same nonce")
+	}
+	tok[len(tok)-1] ^= 1
+	if _, err := s.Refresh(tok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("have %v, want %v", err, signer.ErrUnverified)
+	}
+
+	short, err := signer.NewTruncated(key, 64)
+	ck(t, "new truncated", err)
+	tok, err = short.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	tok, err = short.Rewrap(tok, s)
+	ck(t, "rewrap", err)
+	_, err = s.Verify(tok)
+	ck(t, "verify rewrapped", err)
+}
+
+// repeatReader returns the same bytes from every Read
+type repeatReader byte
+
+func (r repeatReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r)
+	}
+	return len(p), nil
+}
+
+func TestStrictNonce(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	s.Guard = signer.NewNonceGuard(16)
+	s.StrictNonce = true
+	s.Rand = repeatReader(7)
+	_, err = s.Sign([]byte("hello"), nil)
+	ck(t, "sign", err)
+	if _, err := s.Sign([]byte("world"), nil); err != signer.ErrNonceReused {
+		t.Fatalf("have %v, want %v", err, signer.ErrNonceReused)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	tok := signer.Token(vectorTab[0].binary)
+	ck(t, "check", s.Check(tok))
+	if err := s.Check(tok[:10]); !errors.Is(err, signer.ErrShort) {
+		t.Fatalf("short: have %v, want %v", err, signer.ErrShort)
+	}
+	bad := append(signer.Token(nil), tok...)
+	bad[0] = 'Z'
+	if err := s.Check(bad); err != signer.ErrVersion {
+		t.Fatalf("version: have %v, want %v", err, signer.ErrVersion)
+	}
+	bad[0] = tok[0]
+	bad[len(bad)-1] ^= 1
+	if err := s.Check(bad); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("tag: have %v, want %v", err, signer.ErrUnverified)
+	}
+}
+
+func TestSignKind(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	tok, err := s.SignKind(7, []byte("hello world"), nil)
+	ck(t, "sign", err)
+	kind, err := signer.Kind(tok)
+	ck(t, "kind", err)
+	if kind != 7 {
+		t.Fatalf("kind: have %d, want 7", kind)
+	}
+	kind, msg, err := s.VerifyKind(tok)
+	ck(t, "verify", err)
+	if kind != 7 || string(msg) != "hello world" {
+		t.Fatalf("have %d, %q, want 7, %q", kind, msg, "hello world")
+	}
+	tok[1+24+1] = 8 // version, nonce, flags, kind
+	if _, _, err := s.VerifyKind(tok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("tampered kind: have %v, want %v", err, signer.ErrUnverified)
+	}
+	if _, err := signer.Kind(signer.Token(vectorTab[0].binary)); err != signer.ErrNoKind {
+		t.Fatalf("no kind: have %v, want %v", err, signer.ErrNoKind)
+	}
+}
+
+func TestSignOpts(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	msg := bytes.Repeat([]byte("hello world "), 20)
+	for _, opts := range []signer.SignOptions{
+		{Compress: true, Pad: 32},
+		{AAD: []byte("user 1"), Kind: 3, HasKind: true},
+		{TTL: time.Hour, Label: []byte("eu-west"), Compress: true},
+		{AAD: []byte("user 1"), Label: []byte("eu-west"), Pad: 64},
+	} {
+		tok, err := s.SignOpts(msg, opts)
+		ck(t, "sign", err)
+		p, err := s.VerifyOpts(tok, opts)
+		ck(t, "verify", err)
+		if !bytes.Equal(p, msg) {
+			t.Fatalf("%+v: have %q, want %q", opts, p, msg)
+		}
+		if opts.Label != nil {
+			label, _ := signer.Label(tok)
+			if !bytes.Equal(label, opts.Label) {
+				t.Fatalf("%+v: label: have %q, want %q", opts, label, opts.Label)
+			}
+		}
+		other := opts
+		other.Kind, other.HasKind = 9, true
+		if _, err := s.VerifyOpts(tok, other); err != signer.ErrOptions {
+			t.Fatalf("%+v: wrong kind: have %v, want %v", opts, err, signer.ErrOptions)
+		}
+	}
+}
+
+func TestSafeSignBudget(t *testing.T) {
+	key := vectorTab[0].key[:]
+	x, err := signer.New(key)
+	ck(t, "new", err)
+	if have := x.SafeSignBudget(); have != math.MaxUint64 {
+		t.Fatalf("random 24-byte nonces: have %d, want %d", have, uint64(math.MaxUint64))
+	}
+
+	gcm, err := signer.NewAESGCM(key)
+	ck(t, "new gcm", err)
+	for i := 0; i < 3; i++ {
+		_, err := gcm.Sign(nil, nil)
+		ck(t, "sign", err)
+	}
+	if have := gcm.SafeSignBudget(); have != 1<<32-3 {
+		t.Fatalf("random 12-byte nonces: have %d, want %d", have, uint64(1<<32-3))
+	}
+
+	c, err := signer.NewCounterNonce()
+	ck(t, "counter", err)
+	c.Limit = 10
+	x.Rand = c
+	for i := 0; i < 4; i++ {
+		_, err := x.Sign(nil, nil)
+		ck(t, "sign", err)
+	}
+	if have := x.SafeSignBudget(); have != 6 {
+		t.Fatalf("counter nonces: have %d, want 6", have)
+	}
+}
+
+func TestSignAliasing(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	buf := make([]byte, 100)
+	copy(buf, "hello world")
+	msg, nonce := buf[:11], buf[50:50+signer.NonceSize]
+	tok, err := s.Sign(msg, nonce)
+	ck(t, "sign", err)
+	want := append(signer.Token(nil), tok...)
+	for i := range buf {
+		buf[i] = 0xff
+	}
+	if !bytes.Equal(tok, want) {
+		t.Fatalf("token changed when its inputs were modified")
+	}
+	p, err := s.Verify(tok)
+	ck(t, "verify", err)
+	if string(p) != "hello world" {
+		t.Fatalf("have %q, want %q", p, "hello world")
+	}
+
+	if _, err := s.Sign(buf[:40], buf[30:30+signer.NonceSize]); err != signer.ErrOverlap {
+		t.Fatalf("overlapping nonce: have %v, want %v", err, signer.ErrOverlap)
+	}
+	if _, err := s.SignTo(buf[:0], buf[:11], nil); err != signer.ErrOverlap {
+		t.Fatalf("overlapping dst: have %v, want %v", err, signer.ErrOverlap)
+	}
+	if _, err := s.SignUniform(buf[:40], buf[30:30+signer.NonceSize], 64); err != signer.ErrOverlap {
+		t.Fatalf("uniform, overlapping nonce: have %v, want %v", err, signer.ErrOverlap)
+	}
+}
+
+func TestChain(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	c := signer.NewChain(s)
+	var entries []signer.Token
+	for i := 0; i < 5; i++ {
+		tok, err := c.Append([]byte{byte(i)})
+		ck(t, "append", err)
+		entries = append(entries, tok)
+	}
+	ck(t, "verify", c.VerifyChain(entries))
+
+	if err := c.VerifyChain(entries[:4]); err != signer.ErrTruncated {
+		t.Fatalf("dropped last: have %v, want %v", err, signer.ErrTruncated)
+	}
+	swapped := append([]signer.Token(nil), entries...)
+	swapped[1], swapped[2] = swapped[2], swapped[1]
+	if err := c.VerifyChain(swapped); err != signer.ErrChainOrder {
+		t.Fatalf("reordered: have %v, want %v", err, signer.ErrChainOrder)
+	}
+	tampered := append([]signer.Token(nil), entries...)
+	tampered[3] = append(signer.Token(nil), entries[3]...)
+	tampered[3][len(tampered[3])-1] ^= 1
+	if err := c.VerifyChain(tampered); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("tampered: have %v, want %v", err, signer.ErrUnverified)
+	}
+}
+
+func TestVerifyChainRestart(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	c := signer.NewChain(s)
+	var entries []signer.Token
+	for i := 0; i < 3; i++ {
+		tok, err := c.Append([]byte{byte(i)})
+		ck(t, "append", err)
+		entries = append(entries, tok)
+	}
+	head := c.Head()
+
+	// as if after a restart, with only the key, entries, and head
+	s, err = signer.New(vectorTab[0].key[:])
+	ck(t, "new after restart", err)
+	ck(t, "verify without head", signer.VerifyChain(s, entries, nil))
+	ck(t, "verify with head", signer.VerifyChain(s, entries, &head))
+	if err := signer.VerifyChain(s, entries[:2], &head); err != signer.ErrTruncated {
+		t.Fatalf("dropped last: have %v, want %v", err, signer.ErrTruncated)
+	}
+	ck(t, "verify prefix without head", signer.VerifyChain(s, entries[:2], nil))
+	if err := signer.VerifyChain(s, entries[1:], nil); err != signer.ErrChainOrder {
+		t.Fatalf("dropped first: have %v, want %v", err, signer.ErrChainOrder)
+	}
+
+	c = signer.ResumeChain(s, head)
+	tok, err := c.Append([]byte{3})
+	ck(t, "append after restart", err)
+	entries = append(entries, tok)
+	ck(t, "verify resumed", c.VerifyChain(entries))
+	if err := signer.VerifyChain(s, entries, &head); err != signer.ErrChainOrder {
+		t.Fatalf("stale head: have %v, want %v", err, signer.ErrChainOrder)
+	}
+}
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.t }
+
+func TestJWT(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	clock := &fakeClock{time.Unix(1600000000, 0)}
+	s.Clock = clock
+	jwt, err := s.SignJWT(map[string]interface{}{
+		"sub": "user 1",
+		"iat": 1600000000,
+		"exp": 1600000060,
+	})
+	ck(t, "sign", err)
+	claims, err := s.VerifyJWT(jwt)
+	ck(t, "verify", err)
+	if claims["sub"] != "user 1" || claims["exp"] != float64(1600000060) {
+		t.Fatalf("have claims %v", claims)
+	}
+	if _, err := s.VerifyJWT(jwt[:len(jwt)-2] + "AA"); err != signer.ErrUnverified {
+		t.Fatalf("bad signature: have %v, want %v", err, signer.ErrUnverified)
+	}
+
+	// a partner checks the JWT with JWTKey, which is not the Signer's key
+	key, err := s.JWTKey()
+	ck(t, "jwt key", err)
+	if len(key) != 32 || bytes.Equal(key, vectorTab[0].key[:]) {
+		t.Fatalf("have jwt key %x", key)
+	}
+	i := strings.LastIndexByte(jwt, '.')
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(jwt[:i]))
+	if want := base64.RawURLEncoding.EncodeToString(mac.Sum(nil)); jwt[i+1:] != want {
+		t.Fatalf("have signature %s, want %s", jwt[i+1:], want)
+	}
+	clock.t = clock.t.Add(time.Minute)
+	if _, err := s.VerifyJWT(jwt); err != signer.ErrExpired {
+		t.Fatalf("expired: have %v, want %v", err, signer.ErrExpired)
+	}
+}
+
+func TestStreamFrameSize(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	for _, size := range []int{0, signer.MinFrameSize, 4 << 10, 1 << 20} {
+		n := size
+		if n == 0 {
+			n = signer.DefaultFrameSize
+		}
+		msg := make([]byte, 3*n+n/2)
+		_, err := rand.Read(msg)
+		ck(t, "rand", err)
+		var b bytes.Buffer
+		w, err := s.NewEncryptWriter(&b, signer.StreamOptions{FrameSize: size})
+		ck(t, "writer", err)
+		_, err = w.Write(msg)
+		ck(t, "write", err)
+		ck(t, "close", w.Close())
+
+		r, err := s.NewDecryptReader(&b)
+		ck(t, "reader", err)
+		have, err := ioutil.ReadAll(r)
+		ck(t, "read", err)
+		if !bytes.Equal(have, msg) {
+			t.Fatalf("frame size %d: stream did not round trip", size)
+		}
+	}
+	if _, err := s.NewEncryptWriter(ioutil.Discard, signer.StreamOptions{FrameSize: signer.MaxFrameSize + 1}); err != signer.ErrFrameSize {
+		t.Fatalf("have %v, want %v", err, signer.ErrFrameSize)
+	}
+	stream := append([]byte{signer.Version | 0x80}, make([]byte, signer.NonceSize)...)
+	stream = append(stream, 0, 0, 0, 1)
+	if _, err := s.NewDecryptReader(bytes.NewReader(stream)); err != signer.ErrFrameSize {
+		t.Fatalf("have %v, want %v", err, signer.ErrFrameSize)
+	}
+}
+
+func TestStreamHeaderAuthenticated(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	var b bytes.Buffer
+	w, err := s.NewEncryptWriter(&b, signer.StreamOptions{FrameSize: 4 << 10})
+	ck(t, "writer", err)
+	_, err = w.Write([]byte("hello world"))
+	ck(t, "write", err)
+	ck(t, "close", w.Close())
+
+	// a larger declared frame size still fits every frame, so only the
+	// associated data can catch it
+	stream := append([]byte(nil), b.Bytes()...)
+	stream[1+signer.NonceSize+2] = 0x20
+	r, err := s.NewDecryptReader(bytes.NewReader(stream))
+	ck(t, "reader", err)
+	if _, err := ioutil.ReadAll(r); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("altered frame size: have %v, want %v", err, signer.ErrUnverified)
+	}
+}
+
+func TestVerifyWithNonce(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	tok, err := s.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	msg, nonce, err := s.VerifyWithNonce(tok)
+	ck(t, "verify", err)
+	replay, err := s.Sign(msg, nonce)
+	ck(t, "sign", err)
+	if !bytes.Equal(replay, tok) {
+		t.Fatalf("replayed token differs from the original")
+	}
+}
+
+func TestTokenCiphertext(t *testing.T) {
+	tok := signer.Token(vectorTab[0].binary)
+	ct, err := tok.Ciphertext()
+	ck(t, "ciphertext", err)
+	if len(ct) != len(tok)-(1+24) {
+		t.Fatalf("have %d bytes, want %d", len(ct), len(tok)-(1+24))
+	}
+	ct[0] ^= 1
+	if tok[1+24] != vectorTab[0].binary[1+24]^1 {
+		t.Fatalf("modifying the ciphertext did not modify the token")
+	}
+	if _, err := tok[:1+24+15].Ciphertext(); err != signer.ErrTruncatedTag {
+		t.Fatalf("have %v, want %v", err, signer.ErrTruncatedTag)
+	}
+	if _, err := tok[:1+23].Ciphertext(); err != signer.ErrShort {
+		t.Fatalf("short header: have %v, want %v", err, signer.ErrShort)
+	}
+}
+
+func TestTruncatedTag(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	tok := signer.Token(vectorTab[0].binary) // an empty msg, so the token ends with the header and tag
+	for _, n := range []int{1, 8, 16} {
+		if _, err := s.Verify(tok[:len(tok)-n]); err != signer.ErrTruncatedTag {
+			t.Fatalf("clipped %d bytes: have %v, want %v", n, err, signer.ErrTruncatedTag)
+		}
+	}
+	tok[len(tok)-1] ^= 1
+	if _, err := s.Verify(tok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("flipped bit: have %v, want %v", err, signer.ErrUnverified)
+	}
+}
+
+func TestWithKey(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	old := s.Clone()
+	s.MaxMsgSize = 100
+	ck(t, "with key", s.WithKey(bytes.Repeat([]byte{1}, signer.KeySize)))
+	tok, err := s.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	_, err = s.Verify(tok)
+	ck(t, "verify", err)
+	if _, err := old.Verify(tok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("old key: have %v, want %v", err, signer.ErrUnverified)
+	}
+	if s.MaxMsgSize != 100 {
+		t.Fatalf("MaxMsgSize was not kept")
+	}
+	if _, err := s.Sign(make([]byte, 101), nil); err != signer.ErrTooLong {
+		t.Fatalf("have %v, want %v", err, signer.ErrTooLong)
+	}
+	if err := s.WithKey(make([]byte, 16)); err != signer.ErrKeyLen {
+		t.Fatalf("short key: have %v, want %v", err, signer.ErrKeyLen)
+	}
+}
+
+func TestVerifyConstantTime(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	tok, err := s.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	msg, ok := s.VerifyConstantTime(tok)
+	if !ok || string(msg) != "hello world" {
+		t.Fatalf("have %q, %v, want %q, true", msg, ok, "hello world")
+	}
+	tampered := append(signer.Token(nil), tok...)
+	tampered[len(tampered)-1] ^= 1
+	version := append(signer.Token(nil), tok...)
+	version[0] = 'C'
+	for name, tok := range map[string]signer.Token{
+		"empty":    nil,
+		"short":    tok[:10],
+		"notag":    tok[:len(tok)-s.Overhead()+1],
+		"version":  version,
+		"tampered": tampered,
+	} {
+		if msg, ok := s.VerifyConstantTime(tok); ok || msg != nil {
+			t.Fatalf("%s: have %q, %v, want nil, false", name, msg, ok)
+		}
+	}
+}
+
+type ctxKey string
+
+func TestSignCtx(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	s.ContextAAD = signer.ContextKeys(ctxKey("trace"), ctxKey("tenant"))
+	ctx := context.WithValue(context.Background(), ctxKey("trace"), "abc123")
+	tok, err := s.SignCtx(ctx, []byte("hello world"), nil)
+	ck(t, "sign", err)
+	msg, err := s.VerifyCtx(ctx, tok)
+	ck(t, "verify", err)
+	if string(msg) != "hello world" {
+		t.Fatalf("have %q, want %q", msg, "hello world")
+	}
+	for name, ctx := range map[string]context.Context{
+		"other trace": context.WithValue(context.Background(), ctxKey("trace"), "abc124"),
+		"no trace":    context.Background(),
+		"tenant":      context.WithValue(ctx, ctxKey("tenant"), "t1"),
+	} {
+		if _, err := s.VerifyCtx(ctx, tok); !errors.Is(err, signer.ErrUnverified) {
+			t.Fatalf("%s: have %v, want %v", name, err, signer.ErrUnverified)
+		}
+	}
+	if _, err := s.Verify(tok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("verify without context: have %v, want %v", err, signer.ErrUnverified)
+	}
+}
+
+type jsonClaims struct {
+	User  string   `json:"user"`
+	Roles []string `json:"roles"`
+}
+
+// jsonProbe counts the times it is unmarshaled
+type jsonProbe struct{}
+
+var jsonProbeCalls int
+
+func (*jsonProbe) UnmarshalJSON([]byte) error {
+	jsonProbeCalls++
+	return nil
+}
+
+func TestSignJSON(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	want := jsonClaims{User: "alice", Roles: []string{"admin", "dev"}}
+	tok, err := signer.SignJSON(s, want)
+	ck(t, "sign", err)
+	have, err := signer.VerifyJSON[jsonClaims](s, tok)
+	ck(t, "verify", err)
+	if have.User != want.User || len(have.Roles) != 2 || have.Roles[1] != "dev" {
+		t.Fatalf("have %+v, want %+v", have, want)
+	}
+
+	jsonProbeCalls = 0
+	_, err = signer.VerifyJSON[jsonProbe](s, tok)
+	ck(t, "verify probe", err)
+	if jsonProbeCalls != 1 {
+		t.Fatalf("have %d unmarshals, want 1", jsonProbeCalls)
+	}
+	tok[len(tok)-1] ^= 1
+	_, err = signer.VerifyJSON[jsonProbe](s, tok)
+	if !errors.Is(err, signer.ErrUnverified) || errors.Is(err, signer.ErrUnmarshal) {
+		t.Fatalf("tampered: have %v, want %v", err, signer.ErrUnverified)
+	}
+	if jsonProbeCalls != 1 {
+		t.Fatalf("tampered: unmarshal was attempted")
+	}
+
+	if _, err := signer.SignJSON(s, func() {}); !errors.Is(err, signer.ErrMarshal) {
+		t.Fatalf("marshal: have %v, want %v", err, signer.ErrMarshal)
+	}
+	tok, err = s.Sign([]byte("not json"), nil)
+	ck(t, "sign", err)
+	if _, err := signer.VerifyJSON[jsonClaims](s, tok); !errors.Is(err, signer.ErrUnmarshal) {
+		t.Fatalf("unmarshal: have %v, want %v", err, signer.ErrUnmarshal)
+	}
+}
+
+func TestVerifyOnce(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	clock := &fakeClock{time.Unix(1600000000, 0)}
+	guard := signer.NewMemoryReplayGuard(time.Hour)
+	guard.Clock = clock
+	tok1, err := s.Sign([]byte("reset alice"), nil)
+	ck(t, "sign", err)
+	tok2, err := s.Sign([]byte("reset alice"), nil)
+	ck(t, "sign", err)
+
+	msg, err := s.VerifyOnce(tok1, guard)
+	ck(t, "first use", err)
+	if string(msg) != "reset alice" {
+		t.Fatalf("have %q, want %q", msg, "reset alice")
+	}
+	if _, err := s.VerifyOnce(tok1, guard); err != signer.ErrReplayed {
+		t.Fatalf("second use: have %v, want %v", err, signer.ErrReplayed)
+	}
+	_, err = s.VerifyOnce(tok2, guard)
+	ck(t, "other token", err)
+
+	tok1[len(tok1)-1] ^= 1
+	if _, err := s.VerifyOnce(tok1, guard); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("tampered: have %v, want %v", err, signer.ErrUnverified)
+	}
+	tok1[len(tok1)-1] ^= 1
+
+	clock.t = clock.t.Add(time.Hour)
+	_, err = s.VerifyOnce(tok1, guard)
+	ck(t, "after ttl", err)
+}
+
+func TestSignFixed(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	tr, err := signer.NewTruncated(vectorTab[0].key[:], signer.MinTagBits)
+	ck(t, "new truncated", err)
+	for _, s := range []*signer.Signer{s, tr} {
+		for _, n := range []int{0, 1, 16, 100, 4096} {
+			size := s.FixedTokenSize(n)
+			tok, err := s.SignFixed(make([]byte, n), nil, size)
+			ck(t, "sign "+strconv.Itoa(n), err)
+			if len(tok) != size {
+				t.Fatalf("%d: have %d bytes, want %d", n, len(tok), size)
+			}
+		}
+	}
+	want := s.FixedTokenSize(16)
+	if _, err := tr.SignFixed(make([]byte, 16), nil, want); err != signer.ErrTokenSize {
+		t.Fatalf("mismatch: have %v, want %v", err, signer.ErrTokenSize)
+	}
+}
+
+// limitWriter fails once more than n bytes are written to it
+type limitWriter struct {
+	n   int
+	buf bytes.Buffer
+}
+
+var errLimit = errors.New("write limit reached")
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if w.buf.Len()+len(p) > w.n {
+		return 0, errLimit
+	}
+	return w.buf.Write(p)
+}
+
+func TestVerifyToWriter(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	msg := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+	tok, err := s.Sign(msg, nil)
+	ck(t, "sign", err)
+
+	var buf bytes.Buffer
+	n, err := s.VerifyToWriter(&buf, tok)
+	ck(t, "verify", err)
+	if n != int64(len(msg)) || !bytes.Equal(buf.Bytes(), msg) {
+		t.Fatalf("have %d bytes, want %d", n, len(msg))
+	}
+
+	w := &limitWriter{n: 50000}
+	n, err = s.VerifyToWriter(w, tok)
+	if err != errLimit {
+		t.Fatalf("failing writer: have %v, want %v", err, errLimit)
+	}
+	if n != int64(w.buf.Len()) || n == 0 || n >= int64(len(msg)) {
+		t.Fatalf("failing writer: have %d bytes written, writer has %d", n, w.buf.Len())
+	}
+
+	tok[len(tok)-1] ^= 1
+	w = &limitWriter{n: len(msg)}
+	n, err = s.VerifyToWriter(w, tok)
+	if !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("tampered: have %v, want %v", err, signer.ErrUnverified)
+	}
+	if n != 0 || w.buf.Len() != 0 {
+		t.Fatalf("tampered: %d bytes written", w.buf.Len())
+	}
+}
+
+func TestNewRotating(t *testing.T) {
+	k1 := bytes.Repeat([]byte{1}, signer.KeySize)
+	k2 := bytes.Repeat([]byte{2}, signer.KeySize)
+	k3 := bytes.Repeat([]byte{3}, signer.KeySize)
+	old, err := signer.NewRotating(k1, nil)
+	ck(t, "new", err)
+	oldTok, err := old.Sign([]byte("minted before rotation"), nil)
+	ck(t, "sign", err)
+
+	s, err := signer.NewRotating(k2, k1)
+	ck(t, "rotate", err)
+	tok, err := s.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	if _, err := old.Verify(tok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("signed with previous: have %v, want %v", err, signer.ErrUnverified)
+	}
+	for _, tok := range []signer.Token{tok, oldTok} {
+		_, err = s.Verify(tok)
+		ck(t, "verify", err)
+		// in place, so a failed attempt with the current key clears it
+		in := append(signer.Token(nil), tok...)
+		n := s.TokenSize(0) - s.Overhead()
+		_, err = s.VerifyInto(in[n:n], in)
+		ck(t, "verify in place", err)
+		if _, ok := s.VerifyConstantTime(tok); !ok {
+			t.Fatalf("verify constant time failed")
+		}
+	}
+
+	stale, err := signer.New(k3)
+	ck(t, "new stale", err)
+	tok, err = stale.Sign([]byte("hello world"), nil)
+	ck(t, "sign stale", err)
+	if _, err := s.Verify(tok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("stale key: have %v, want %v", err, signer.ErrUnverified)
+	}
+	if _, err := signer.NewRotating(k2, k1[:16]); err != signer.ErrKeyLen {
+		t.Fatalf("short previous: have %v, want %v", err, signer.ErrKeyLen)
+	}
+}
+
+func TestValidateLayout(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	tok, err := s.SignWithTTL([]byte("hello world"), time.Hour)
+	ck(t, "sign", err)
+	ck(t, "valid", signer.ValidateLayout(tok))
+	for _, tc := range []struct {
+		name string
+		tok  signer.Token
+		want error
+	}{
+		{"empty", nil, signer.ErrShort},
+		{"version", signer.Token("Z0123456789"), signer.ErrVersion},
+		{"header", tok[:20], signer.ErrShort},
+		{"tag", tok[:len(tok)-len("hello world")-1], signer.ErrTruncatedTag},
+	} {
+		if err := signer.ValidateLayout(tc.tok); err != tc.want {
+			t.Fatalf("%s: have %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func FuzzValidateLayout(f *testing.F) {
+	key := vectorTab[0].key[:]
+	a, _ := signer.New(key)
+	c, _ := signer.NewAESGCM(key)
+	d, _ := signer.NewStandard(key)
+	signers := map[byte]*signer.Signer{signer.Version: a, signer.VersionGCM: c, signer.VersionIETF: d}
+	f.Add([]byte(vectorTab[0].binary))
+	for _, s := range signers {
+		tok, _ := s.Sign([]byte("hello world"), nil)
+		f.Add([]byte(tok))
+		tok, _ = s.SignWithTTL([]byte("hello world"), time.Hour)
+		f.Add([]byte(tok[:len(tok)-1]))
+	}
+	f.Fuzz(func(t *testing.T, p []byte) {
+		tok := signer.Token(p)
+		err := signer.ValidateLayout(tok)
+		if (err == nil) != tok.Valid() {
+			t.Fatalf("ValidateLayout is %v, but Valid is %v", err, tok.Valid())
+		}
+		v, _, _, verr := signer.Split(tok)
+		s := signers[v]
+		if err != nil || verr != nil || s == nil {
+			return
+		}
+		// a valid layout gets Verify as far as Open, unless the token
+		// was made by a Signer of another tag size
+		_, err = s.Verify(tok)
+		if err != nil && !errors.Is(err, signer.ErrUnverified) && err != signer.ErrVersion {
+			t.Fatalf("valid layout, but Verify returned %v", err)
+		}
+	})
+}
+
+func TestNoncePool(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	pool := signer.NewNoncePool(s.NonceSize(), 16)
+	s.Rand = pool
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		if i == 500 {
+			// the rest are read directly
+			ck(t, "close pool", pool.Close())
+		}
+		_, nonce, err := s.SignN([]byte("hello world"))
+		ck(t, "sign", err)
+		if seen[string(nonce)] {
+			t.Fatalf("nonce %x reused", nonce)
+		}
+		seen[string(nonce)] = true
+	}
+	ck(t, "close pool again", pool.Close())
+
+	for _, size := range []int{-1, 0, signer.NonceSize + 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("size %d: want a panic", size)
+				}
+			}()
+			signer.NewNoncePool(size, 16)
+		}()
+	}
+}
+
+func BenchmarkNonce(b *testing.B) {
+	msg := make([]byte, 64)
+	for _, pooled := range []bool{false, true} {
+		b.Run("pooled="+strconv.FormatBool(pooled), func(b *testing.B) {
+			s, _ := signer.New(vectorTab[0].key[:])
+			if pooled {
+				pool := signer.NewNoncePool(s.NonceSize(), 1024)
+				defer pool.Close()
+				s.Rand = pool
+			}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s.Sign(msg, nil)
+			}
+		})
+	}
+}
+
+func TestIdempotencyToken(t *testing.T) {
+	s1, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	s2, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	in := []byte(`{"op":"charge","amount":100}`)
+	t1, err := s1.IdempotencyToken(in)
+	ck(t, "token", err)
+	again, err := s1.IdempotencyToken(in)
+	ck(t, "token again", err)
+	t2, err := s2.IdempotencyToken(in)
+	ck(t, "other instance", err)
+	if !bytes.Equal(t1, again) || !bytes.Equal(t1, t2) {
+		t.Fatalf("tokens differ: %x, %x, %x", t1, again, t2)
+	}
+	other, err := s1.IdempotencyToken([]byte(`{"op":"charge","amount":101}`))
+	ck(t, "other input", err)
+	if bytes.Equal(t1, other) {
+		t.Fatalf("different inputs have the same token")
+	}
+	msg, err := s2.Verify(t1)
+	ck(t, "verify", err)
+	if !bytes.Equal(msg, in) {
+		t.Fatalf("have %q, want %q", msg, in)
+	}
+}
+
+// panicAEAD is an AEAD that panics, like one given a nonce of the wrong size
+type panicAEAD struct{ cipher.AEAD }
+
+func (panicAEAD) Seal(dst, nonce, plaintext, ad []byte) []byte { panic("seal: bad nonce length") }
+func (panicAEAD) Open(dst, nonce, ciphertext, ad []byte) ([]byte, error) {
+	panic(errors.New("open: bad nonce length"))
+}
+
+func TestInternal(t *testing.T) {
+	good, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	tok, err := good.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	s, err := signer.NewWithAEAD(panicAEAD{good.AEAD()})
+	ck(t, "new with aead", err)
+
+	if tok, err := s.Sign([]byte("hello world"), nil); !errors.Is(err, signer.ErrInternal) || tok != nil {
+		t.Fatalf("sign: have %x, %v, want nil, %v", tok, err, signer.ErrInternal)
+	}
+	if tok, err := s.SignTo(nil, []byte("hello world"), nil); !errors.Is(err, signer.ErrInternal) || tok != nil {
+		t.Fatalf("sign to: have %x, %v, want nil, %v", tok, err, signer.ErrInternal)
+	}
+	_, err = s.Verify(tok)
+	if !errors.Is(err, signer.ErrInternal) || errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("verify: have %v, want %v", err, signer.ErrInternal)
+	}
+	if errors.Unwrap(err) == nil {
+		t.Fatalf("verify: %v does not wrap the panic", err)
+	}
+	if _, ok := s.VerifyConstantTime(tok); ok {
+		t.Fatalf("verify constant time succeeded")
+	}
+
+	var buf bytes.Buffer
+	w, err := good.NewEncryptWriter(&buf)
+	ck(t, "new encrypt writer", err)
+	io.WriteString(w, "hello world")
+	ck(t, "close", w.Close())
+	r, err := s.NewDecryptReader(&buf)
+	ck(t, "new decrypt reader", err)
+	if _, err := ioutil.ReadAll(r); !errors.Is(err, signer.ErrInternal) {
+		t.Fatalf("decrypt: have %v, want %v", err, signer.ErrInternal)
+	}
+}
+
+func TestShortTagAEAD(t *testing.T) {
+	b, err := aes.NewCipher(vectorTab[0].key[:])
+	ck(t, "new cipher", err)
+	aead, err := cipher.NewGCMWithTagSize(b, 12)
+	ck(t, "new gcm", err)
+	s, err := signer.NewWithAEAD(aead)
+	ck(t, "new", err)
+	for _, msg := range []string{"", "abc", "hello world"} {
+		tok, err := s.Sign([]byte(msg), nil)
+		ck(t, "sign", err)
+		have, err := s.Verify(tok)
+		ck(t, "verify "+strconv.Quote(msg), err)
+		if string(have) != msg {
+			t.Fatalf("have %q, want %q", have, msg)
+		}
+		if _, err := s.Verify(tok[:len(tok)-len(msg)-1]); err != signer.ErrTruncatedTag {
+			t.Fatalf("cut tag: have %v, want %v", err, signer.ErrTruncatedTag)
+		}
+	}
+}
+
+func TestChainConcurrent(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	c := signer.NewChain(s)
+	var (
+		mu      sync.Mutex
+		entries []signer.Token
+	)
+	next := make(chan bool, 1)
+	done := make(chan bool)
+	go func() {
+		// append an entry each time the verifier starts on the whole chain
+		defer close(done)
+		for range next {
+			e, err := c.Append([]byte("entry"))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			entries = append(entries, e)
+			mu.Unlock()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		mu.Lock()
+		snapshot := append([]signer.Token(nil), entries...)
+		mu.Unlock()
+		next <- true
+		// the chain may have grown since the snapshot
+		if err := c.VerifyChain(snapshot); err != nil && err != signer.ErrTruncated {
+			t.Fatal(err)
+		}
+	}
+	close(next)
+	<-done
+	ck(t, "verify", c.VerifyChain(entries))
+}
+
+func TestTag(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	msg := []byte("hello world")
+	tag, err := s.Tag(msg, nil)
+	ck(t, "tag", err)
+	if len(tag) != s.TokenSize(0)+1 {
+		t.Fatalf("have %d bytes, want %d", len(tag), s.TokenSize(0)+1)
+	}
+	ck(t, "verify tag", s.VerifyTag(tag, msg))
+	if err := s.VerifyTag(tag, []byte("hello worle")); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("modified msg: have %v, want %v", err, signer.ErrUnverified)
+	}
+
+	// a tag of attacker-chosen data is not a token for a purpose or aad
+	forged, err := s.Tag([]byte("signer purpose\x00download"), nil)
+	ck(t, "tag purpose", err)
+	if _, err := s.VerifyFor("download", forged); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("tag as purpose token: have %v, want %v", err, signer.ErrUnverified)
+	}
+	if _, err := s.VerifyWithAAD(tag, msg); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("tag as aad token: have %v, want %v", err, signer.ErrUnverified)
+	}
+	if _, ok := s.VerifyConstantTime(tag); ok {
+		t.Fatalf("tag verified as a token")
+	}
+	empty, err := s.SignWithAAD(nil, msg, nil)
+	ck(t, "sign with aad", err)
+	if err := s.VerifyTag(empty, msg); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("aad token as tag: have %v, want %v", err, signer.ErrUnverified)
+	}
+}
+
+func TestInternalHookPanic(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	s.OnSign = func(int) { panic("hook") }
+	for name, sign := range map[string]func() (signer.Token, error){
+		"sign":    func() (signer.Token, error) { return s.Sign([]byte("hello world"), nil) },
+		"sign to": func() (signer.Token, error) { return s.SignTo(nil, []byte("hello world"), nil) },
+	} {
+		func() {
+			defer func() {
+				if v := recover(); v != "hook" {
+					t.Fatalf("%s: have panic %v, want the hook's", name, v)
+				}
+			}()
+			tok, err := sign()
+			t.Fatalf("%s: returned %x, %v, want the hook's panic", name, tok, err)
+		}()
+	}
+}
+
+var registerTestFormats sync.Once
+
+func TestRegisterFormat(t *testing.T) {
+	key := vectorTab[0].key[:]
+	b, err := aes.NewCipher(key)
+	ck(t, "new cipher", err)
+	aead, err := cipher.NewGCM(b)
+	ck(t, "new gcm", err)
+	// 'F' tokens come from another system: "Fx" nonce[12] ciphertext
+	legacy := signer.Format{
+		NonceSize: 12,
+		Parse: func(t signer.Token) ([]byte, int, error) {
+			if len(t) < 2+12 || t[1] != 'x' {
+				return nil, 0, signer.ErrShort
+			}
+			return t[2 : 2+12], 2 + 12, nil
+		},
+	}
+	registerTestFormats.Do(func() {
+		ck(t, "register E", signer.RegisterFormat('E', signer.Format{NonceSize: 12}))
+		ck(t, "register F", signer.RegisterFormat('F', legacy))
+	})
+	if err := signer.RegisterFormat('E', signer.Format{NonceSize: 12}); err != signer.ErrVersion {
+		t.Fatalf("registered twice: have %v, want %v", err, signer.ErrVersion)
+	}
+	if err := signer.RegisterFormat('G'|0x80, signer.Format{NonceSize: 12}); err != signer.ErrVersion {
+		t.Fatalf("high bit: have %v, want %v", err, signer.ErrVersion)
+	}
+	if err := signer.RegisterFormat('G', signer.Format{}); err != signer.ErrNonceLen {
+		t.Fatalf("no nonce: have %v, want %v", err, signer.ErrNonceLen)
+	}
+
+	e, err := signer.NewWithFormat(aead, 'E')
+	ck(t, "new E", err)
+	tok, err := e.SignWithTTL([]byte("hello world"), time.Hour)
+	ck(t, "sign E", err)
+	if v, _ := tok.Version(); v != 'E' {
+		t.Fatalf("have version %q, want 'E'", v)
+	}
+	msg, err := e.VerifyFresh(tok)
+	ck(t, "verify E", err)
+	if string(msg) != "hello world" {
+		t.Fatalf("have %q, want %q", msg, "hello world")
+	}
+	c, err := signer.NewWithAEAD(aead)
+	ck(t, "new C", err)
+	if _, err := c.Verify(tok); err != signer.ErrVersion {
+		t.Fatalf("E token with C signer: have %v, want %v", err, signer.ErrVersion)
+	}
+
+	f, err := signer.NewWithFormat(aead, 'F')
+	ck(t, "new F", err)
+	nonce := bytes.Repeat([]byte{7}, 12)
+	hdr := append([]byte("Fx"), nonce...)
+	tok = aead.Seal(hdr, nonce, []byte("from elsewhere"), hdr)
+	msg, err = f.Verify(tok)
+	ck(t, "verify F", err)
+	if string(msg) != "from elsewhere" {
+		t.Fatalf("have %q, want %q", msg, "from elsewhere")
+	}
+	r, err := f.VerifyMeta(tok)
+	ck(t, "verify meta F", err)
+	if !bytes.Equal(r.Nonce, nonce) || r.Version != 'F' {
+		t.Fatalf("have nonce %x, version %q, want %x, 'F'", r.Nonce, r.Version, nonce)
+	}
+	guard := signer.NewMemoryReplayGuard(time.Hour)
+	_, err = f.VerifyOnce(tok, guard)
+	ck(t, "verify once F", err)
+	if !guard.Seen(nonce) {
+		t.Fatalf("replay guard did not mark the nonce %x", nonce)
+	}
+	tok[1] = 'y'
+	if _, err := f.Verify(tok); err != signer.ErrShort {
+		t.Fatalf("bad F header: have %v, want %v", err, signer.ErrShort)
+	}
+	if _, err := f.Sign([]byte("hello world"), nil); err != signer.ErrVersion {
+		t.Fatalf("sign F: have %v, want %v", err, signer.ErrVersion)
+	}
+	if _, err := f.NewEncryptWriter(ioutil.Discard); err != signer.ErrVersion {
+		t.Fatalf("encrypt F: have %v, want %v", err, signer.ErrVersion)
+	}
+	if _, err := f.NewDecryptReader(bytes.NewReader(tok)); err != signer.ErrVersion {
+		t.Fatalf("decrypt F: have %v, want %v", err, signer.ErrVersion)
+	}
+
+	if _, err := signer.NewWithFormat(aead, 'Z'); err != signer.ErrVersion {
+		t.Fatalf("new Z: have %v, want %v", err, signer.ErrVersion)
+	}
+	if _, err := e.Verify(signer.Token("Z0123456789abcdef0123456789")); err != signer.ErrVersion {
+		t.Fatalf("Z token: have %v, want %v", err, signer.ErrVersion)
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				msg := []byte(strconv.Itoa(i) + "/" + strconv.Itoa(j))
+				tok, err := s.Sign(msg, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				p, err := s.Verify(tok)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !bytes.Equal(p, msg) {
+					t.Errorf("have %q, want %q", p, msg)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestNonceLen(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	for _, n := range []int{0, 12, signer.NonceSize - 1, signer.NonceSize + 1, 64} {
+		if _, err := s.Sign([]byte("hello world"), make([]byte, n)); err != signer.ErrNonceLen {
+			t.Fatalf("%d byte nonce: have %v, want %v", n, err, signer.ErrNonceLen)
+		}
+	}
+	_, err = s.Sign([]byte("hello world"), make([]byte, signer.NonceSize))
+	ck(t, "sign", err)
+}
+
+func TestKeyedVerifier(t *testing.T) {
+	legacy, err := signer.New(bytes.Repeat([]byte{1}, signer.KeySize))
+	ck(t, "new", err)
+	k7, err := signer.NewWithKeyID(bytes.Repeat([]byte{7}, signer.KeySize), 7)
+	ck(t, "new key id 7", err)
+	k9, err := signer.NewWithKeyID(bytes.Repeat([]byte{9}, signer.KeySize), 9)
+	ck(t, "new key id 9", err)
+	v := signer.NewKeyedVerifier(legacy, k7, k9)
+	for _, s := range []*signer.Signer{legacy, k7, k9} {
+		tok, err := s.Sign([]byte("hello world"), nil)
+		ck(t, "sign", err)
+		p, err := v.Verify(tok)
+		ck(t, "verify", err)
+		if string(p) != "hello world" {
+			t.Fatalf("version %c: have %q, want %q", tok[0], p, "hello world")
+		}
+	}
+	a, err := legacy.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	if a[0] != signer.Version {
+		t.Fatalf("no key id: have version %c, want %c", a[0], signer.Version)
+	}
+
+	k8, err := signer.NewWithKeyID(bytes.Repeat([]byte{8}, signer.KeySize), 8)
+	ck(t, "new key id 8", err)
+	tok, err := k8.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	if _, err := v.Verify(tok); err != signer.ErrKeyID {
+		t.Fatalf("unknown key id: have %v, want %v", err, signer.ErrKeyID)
+	}
+	if _, err := signer.NewKeyedVerifier(k7).Verify(a); err != signer.ErrKeyID {
+		t.Fatalf("no legacy key: have %v, want %v", err, signer.ErrKeyID)
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	k1 := bytes.Repeat([]byte{1}, signer.KeySize)
+	k2 := bytes.Repeat([]byte{2}, signer.KeySize)
+	old, err := signer.NewKeyring(k1)
+	ck(t, "new", err)
+	oldTok, err := old.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+
+	k, err := signer.NewKeyring(k2, k1)
+	ck(t, "rotate", err)
+	tok, err := k.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	if _, err := old.Verify(tok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("signed with first key: have %v, want %v", err, signer.ErrUnverified)
+	}
+	for _, tok := range []signer.Token{tok, oldTok} {
+		p, err := k.Verify(tok)
+		ck(t, "verify", err)
+		if string(p) != "hello world" {
+			t.Fatalf("have %q, want %q", p, "hello world")
+		}
+	}
+	tok[len(tok)-1] ^= 1
+	if _, err := k.Verify(tok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("modified: have %v, want %v", err, signer.ErrUnverified)
+	}
+	if _, err := signer.NewKeyring(); err != signer.ErrNoKey {
+		t.Fatalf("no keys: have %v, want %v", err, signer.ErrNoKey)
+	}
+}
+
+func TestSignWithAAD(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	tok, err := s.SignWithAAD([]byte("hello world"), []byte("userA"), nil)
+	ck(t, "sign", err)
+	p, err := s.VerifyWithAAD(tok, []byte("userA"))
+	ck(t, "verify", err)
+	if string(p) != "hello world" {
+		t.Fatalf("have %q, want %q", p, "hello world")
+	}
+	for _, aad := range []string{"userB", ""} {
+		if _, err := s.VerifyWithAAD(tok, []byte(aad)); !errors.Is(err, signer.ErrUnverified) {
+			t.Fatalf("aad %q: have %v, want %v", aad, err, signer.ErrUnverified)
+		}
+	}
+	if _, err := s.Verify(tok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("verify: have %v, want %v", err, signer.ErrUnverified)
+	}
+
+	// Sign and Verify use nil associated data
+	tok, err = s.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	_, err = s.VerifyWithAAD(tok, nil)
+	ck(t, "verify nil aad", err)
+}
+
+func TestSignFor(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	tok, err := s.SignFor("reset-password", []byte("user 1"), nil)
+	ck(t, "sign", err)
+	p, err := s.VerifyFor("reset-password", tok)
+	ck(t, "verify", err)
+	if string(p) != "user 1" {
+		t.Fatalf("have %q, want %q", p, "user 1")
+	}
+	if _, err := s.VerifyFor("download", tok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("wrong purpose: have %v, want %v", err, signer.ErrUnverified)
+	}
+	if _, err := s.Verify(tok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("no purpose: have %v, want %v", err, signer.ErrUnverified)
+	}
+	if _, err := s.VerifyWithAAD(tok, []byte("reset-password")); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("purpose as aad: have %v, want %v", err, signer.ErrUnverified)
+	}
+}
+
+func TestSignedCookie(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	w := httptest.NewRecorder()
+	ck(t, "set", s.SetSignedCookie(w, "session", []byte("user 1"), signer.CookieOptions{Path: "/"}))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].Secure || !cookies[0].HttpOnly || cookies[0].Path != "/" {
+		t.Fatalf("have cookies %v", cookies)
+	}
+	c := cookies[0]
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(c)
+	p, err := s.ReadSignedCookie(r, "session")
+	ck(t, "read", err)
+	if string(p) != "user 1" {
+		t.Fatalf("have %q, want %q", p, "user 1")
+	}
+	if _, err := s.ReadSignedCookie(r, "other"); err != signer.ErrNoCookie {
+		t.Fatalf("missing: have %v, want %v", err, signer.ErrNoCookie)
+	}
+
+	// the value is bound to the cookie name
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "other", Value: c.Value})
+	if _, err := s.ReadSignedCookie(r, "other"); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("renamed: have %v, want %v", err, signer.ErrUnverified)
+	}
+
+	// a token bound to the name as plain aad is not a cookie
+	tok, err := s.SignWithAAD([]byte("user 1"), []byte("session"), nil)
+	ck(t, "sign", err)
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: tok.Encode()})
+	if _, err := s.ReadSignedCookie(r, "session"); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("plain aad: have %v, want %v", err, signer.ErrUnverified)
+	}
+	tok, err = signer.ParseToken(c.Value)
+	ck(t, "parse", err)
+	if _, err := s.VerifyWithAAD(tok, []byte("session")); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("cookie as plain aad: have %v, want %v", err, signer.ErrUnverified)
+	}
+
+	tok, err = signer.ParseToken(c.Value)
+	ck(t, "parse", err)
+	tok[len(tok)-1] ^= 1
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: tok.Encode()})
+	if _, err := s.ReadSignedCookie(r, "session"); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("tampered: have %v, want %v", err, signer.ErrUnverified)
+	}
+}
+
+func TestRotatingSigner(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	oldKey := signer.KeyEntry{Key: bytes.Repeat([]byte{1}, signer.KeySize), NotAfter: start.Add(time.Hour)}
+	newKey := signer.KeyEntry{Key: bytes.Repeat([]byte{2}, signer.KeySize), NotAfter: start.Add(48 * time.Hour)}
+	clock := &fakeClock{start}
+	old, err := signer.NewRotatingSigner([]signer.KeyEntry{oldKey})
+	ck(t, "new", err)
+	old.Clock = clock
+	oldTok, err := old.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+
+	r, err := signer.NewRotatingSigner([]signer.KeyEntry{oldKey, newKey})
+	ck(t, "rotate", err)
+	r.Clock = clock
+	tok, err := r.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	if _, err := old.Verify(tok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("signed with newest: have %v, want %v", err, signer.ErrUnverified)
+	}
+	for _, tok := range []signer.Token{tok, oldTok} {
+		_, err = r.Verify(tok)
+		ck(t, "verify", err)
+	}
+
+	clock.t = oldKey.NotAfter.Add(time.Second)
+	if _, err := r.Verify(oldTok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("past NotAfter: have %v, want %v", err, signer.ErrUnverified)
+	}
+	_, err = r.Verify(tok)
+	ck(t, "verify newest", err)
+	if _, err := old.Sign([]byte("hello world"), nil); err != signer.ErrNoKey {
+		t.Fatalf("all expired: have %v, want %v", err, signer.ErrNoKey)
+	}
+}
+
+func FuzzSplit(f *testing.F) {
+	key := vectorTab[0].key[:]
+	a, _ := signer.New(key)
+	b, _ := signer.NewWithKeyID(key, 1)
+	c, _ := signer.NewAESGCM(key)
+	d, _ := signer.NewStandard(key)
+	signers := map[byte]*signer.Signer{signer.Version: a, signer.VersionKeyID: b, signer.VersionGCM: c, signer.VersionIETF: d}
+	f.Add([]byte(vectorTab[0].binary))
+	for _, s := range signers {
+		tok, _ := s.Sign([]byte("hello world"), nil)
+		f.Add([]byte(tok))
+		tok, _ = s.SignLabeled([]byte("label"), []byte("hello world"), nil)
+		f.Add([]byte(tok))
+	}
+	f.Fuzz(func(t *testing.T, p []byte) {
+		tok := signer.Token(p)
+		v, nonce, ciphertext, err := signer.Split(tok)
+		if err != nil {
+			if err != signer.ErrShort && err != signer.ErrVersion {
+				t.Fatalf("have %v, want %v or %v", err, signer.ErrShort, signer.ErrVersion)
+			}
+			return
+		}
+		if v != tok[0]&^0x80 {
+			t.Fatalf("have version %q, token starts with %q", v, tok[0])
+		}
+		// both regions are slices of the token, with the nonce in the
+		// header before the ciphertext
+		nonceAt := offset(t, tok, nonce)
+		ctAt := offset(t, tok, ciphertext)
+		if ctAt+len(ciphertext) != len(tok) {
+			t.Fatalf("ciphertext at %d..%d, want it to end the %d byte token", ctAt, ctAt+len(ciphertext), len(tok))
+		}
+		if nonceAt < 1 || nonceAt+len(nonce) > ctAt {
+			t.Fatalf("nonce at %d..%d, want it after the version and before the ciphertext at %d", nonceAt, nonceAt+len(nonce), ctAt)
+		}
+		if s := signers[v]; s != nil && len(nonce) != s.NonceSize() {
+			t.Fatalf("version %q: have %d byte nonce, want %d", v, len(nonce), s.NonceSize())
+		}
+	})
+}
+
+// offset returns the offset of sub in tok, failing unless sub is a slice of it
+func offset(t *testing.T, tok signer.Token, sub []byte) int {
+	t.Helper()
+	n := cap(tok) - cap(sub)
+	if n < 0 || n+len(sub) > len(tok) || len(sub) > 0 && &tok[n] != &sub[0] {
+		t.Fatalf("%x is not a slice of %x", sub, tok)
+	}
+	return n
+}
+
+func TestSignerTestVectors(t *testing.T) {
+	for _, v := range signertest.TestVectors() {
+		s, err := signer.New(v.Key)
+		ck(t, "new", err)
+		tok, err := s.Sign(v.Msg, v.Nonce)
+		ck(t, "sign", err)
+		if !tok.Equal(v.Token) {
+			t.Fatalf("%s: have %s, want %s", v.Name, tok, v.Token)
+		}
+		p, err := s.Verify(v.Token)
+		ck(t, "verify", err)
+		if !bytes.Equal(p, v.Msg) {
+			t.Fatalf("%s: have %q, want %q", v.Name, p, v.Msg)
+		}
+	}
+}
+
+func TestDecryptReaderFrames(t *testing.T) {
+	const (
+		frameSize = signer.MinFrameSize
+		hdr       = 1 + 24 + 4
+		sealed    = 4 + frameSize + 16
+	)
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	var b bytes.Buffer
+	w, err := s.NewEncryptWriter(&b, signer.StreamOptions{FrameSize: frameSize})
+	ck(t, "writer", err)
+	_, err = w.Write(bytes.Repeat([]byte("a"), 3*frameSize+frameSize/2))
+	ck(t, "write", err)
+	ck(t, "close", w.Close())
+	stream := b.Bytes()
+	frame := func(i int) []byte { return stream[hdr+i*sealed : hdr+(i+1)*sealed] }
+
+	for name, tc := range map[string]struct {
+		edit func(p []byte) []byte
+		want error
+	}{
+		"corrupt middle": {func(p []byte) []byte {
+			p[hdr+sealed+10] ^= 1
+			return p
+		}, signer.ErrUnverified},
+		"reorder": {func(p []byte) []byte {
+			p = append(p[:hdr:hdr], frame(1)...)
+			p = append(p, frame(0)...)
+			return append(p, stream[hdr+2*sealed:]...)
+		}, signer.ErrUnverified},
+		"drop final": {func(p []byte) []byte {
+			return p[:hdr+3*sealed]
+		}, signer.ErrTruncated},
+	} {
+		p := tc.edit(append([]byte(nil), stream...))
+		r, err := s.NewDecryptReader(bytes.NewReader(p))
+		ck(t, "reader", err)
+		if _, err := ioutil.ReadAll(r); !errors.Is(err, tc.want) {
+			t.Fatalf("%s: have %v, want %v", name, err, tc.want)
+		}
+	}
+	r, err := s.NewDecryptReader(bytes.NewReader(stream))
+	ck(t, "reader", err)
+	_, err = ioutil.ReadAll(r)
+	ck(t, "read", err)
+}
+
+func TestSignToAllocs(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	msg := make([]byte, 64)
+	nonce := make([]byte, signer.NonceSize)
+	dst := make([]byte, 0, s.TokenSize(len(msg)))
+	if n := testing.AllocsPerRun(100, func() { s.SignTo(dst, msg, nonce) }); n != 0 {
+		t.Fatalf("have %v allocations, want 0", n)
+	}
+}
+
+func BenchmarkSignTo(b *testing.B) {
+	s, _ := signer.New(vectorTab[0].key[:])
+	nonce := make([]byte, signer.NonceSize)
+	for _, n := range []int{16, 64, 256} {
+		msg := make([]byte, n)
+		dst := make([]byte, 0, s.TokenSize(n))
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s.SignTo(dst, msg, nonce)
+			}
+		})
+	}
+}
+
+func BenchmarkVerifyInto(b *testing.B) {
+	s, _ := signer.New(vectorTab[0].key[:])
+	msg := make([]byte, 1<<20)
+	tok, _ := s.Sign(msg, nil)
+	b.Run("Verify", func(b *testing.B) {
+		b.SetBytes(int64(len(msg)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s.Verify(tok)
+		}
+	})
+	b.Run("VerifyInto", func(b *testing.B) {
+		dst := make([]byte, 0, len(msg))
+		b.SetBytes(int64(len(msg)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			s.VerifyInto(dst, tok)
+		}
+	})
+	b.Run("InPlace", func(b *testing.B) {
+		// decrypting overwrites the token, so each run works on a copy
+		in := make(signer.Token, len(tok))
+		n := s.TokenSize(0) - s.Overhead()
+		b.SetBytes(int64(len(msg)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			copy(in, tok)
+			s.VerifyInto(in[n:n], in)
+		}
+	})
+}
+
+// countReader counts the reads from a randomness source
+type countReader struct{ n int }
+
+func (c *countReader) Read(p []byte) (int, error) {
+	c.n++
+	return rand.Read(p)
+}
+
+func TestSignBatch(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	src := &countReader{}
+	s.Rand = src
+	var msgs [][]byte
+	for i := 0; i < 1000; i++ {
+		msgs = append(msgs, []byte(strconv.Itoa(i)))
+	}
+	tokens, err := s.SignBatch(msgs)
+	ck(t, "sign", err)
+	if len(tokens) != len(msgs) || src.n != 1 {
+		t.Fatalf("have %d tokens from %d reads, want %d from 1", len(tokens), src.n, len(msgs))
+	}
+	seen := map[string]bool{}
+	for i, tok := range tokens {
+		p, err := s.Verify(tok)
+		ck(t, "verify", err)
+		if !bytes.Equal(p, msgs[i]) {
+			t.Fatalf("token %d: have %q, want %q", i, p, msgs[i])
+		}
+		nonce, err := tok.Nonce()
+		ck(t, "nonce", err)
+		if seen[string(nonce)] {
+			t.Fatalf("token %d: nonce %x used twice", i, nonce)
+		}
+		seen[string(nonce)] = true
+	}
+}
+
+func BenchmarkSignBatch(b *testing.B) {
+	s, _ := signer.New(vectorTab[0].key[:])
+	msgs := make([][]byte, 1000)
+	for i := range msgs {
+		msgs[i] = make([]byte, 64)
+	}
+	b.Run("SignBatch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s.SignBatch(msgs)
+		}
+	})
+	b.Run("Sign", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, msg := range msgs {
+				s.Sign(msg, nil)
+			}
+		}
+	})
+}
+
+func TestSignerPool(t *testing.T) {
+	p, err := signer.NewSignerPool(vectorTab[0].key[:])
+	ck(t, "new", err)
+	tok, err := p.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	msg, err := p.Verify(tok)
+	ck(t, "verify", err)
+	if string(msg) != "hello world" {
+		t.Fatalf("have %q, want %q", msg, "hello world")
+	}
+	if _, err := signer.NewSignerPool(vectorTab[0].key[:16]); err != signer.ErrKeyLen {
+		t.Fatalf("short key: have %v, want %v", err, signer.ErrKeyLen)
+	}
+}
+
+func BenchmarkSignerPool(b *testing.B) {
+	key := vectorTab[0].key[:]
+	msg := make([]byte, 64)
+	shared, _ := signer.New(key)
+	pool, _ := signer.NewSignerPool(key)
+	var mu sync.Mutex
+	for _, bb := range []struct {
+		name string
+		sign func() (signer.Token, error)
+	}{
+		{"Shared", func() (signer.Token, error) { return shared.Sign(msg, nil) }},
+		{"Mutex", func() (signer.Token, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return shared.Sign(msg, nil)
+		}},
+		{"Pool", func() (signer.Token, error) { return pool.Sign(msg, nil) }},
+	} {
+		sign := bb.sign
+		b.Run(bb.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetParallelism(16)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					sign()
+				}
+			})
+		})
+	}
+}
+
+func TestEmptyMsg(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	verifiers := map[string]func(signer.Token) ([]byte, error){
+		"verify":      s.Verify,
+		"verify into": func(t signer.Token) ([]byte, error) { return s.VerifyInto(nil, t) },
+		"verify aad":  func(t signer.Token) ([]byte, error) { return s.VerifyWithAAD(t, nil) },
+		"verify constant time": func(t signer.Token) ([]byte, error) {
+			if msg, ok := s.VerifyConstantTime(t); ok {
+				return msg, nil
+			}
+			return nil, signer.ErrUnverified
+		},
+	}
+	for _, msg := range [][]byte{nil, {}} {
+		tok, err := s.Sign(msg, nil)
+		ck(t, "sign", err)
+		if len(tok) != s.TokenSize(0) {
+			t.Fatalf("%#v: have %d byte token, want %d", msg, len(tok), s.TokenSize(0))
+		}
+		for name, verify := range verifiers {
+			p, err := verify(tok)
+			ck(t, name, err)
+			if p == nil || len(p) != 0 {
+				t.Fatalf("%#v: %s: have %#v, want a non-nil empty msg", msg, name, p)
+			}
+			bad := append(signer.Token(nil), tok...)
+			bad[len(bad)-1] ^= 1
+			if p, err := verify(bad); err == nil || p != nil {
+				t.Fatalf("%#v: %s: tampered: have %#v, %v, want nil and an error", msg, name, p, err)
+			}
+		}
+	}
+}
+
+func TestSignDeterministic(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	a, err := s.SignDeterministic([]byte("hello world"))
+	ck(t, "sign", err)
+	b, err := s.SignDeterministic([]byte("hello world"))
+	ck(t, "sign again", err)
+	if !bytes.Equal(a, b) {
+		t.Fatalf("same msg: have %x and %x, want identical tokens", a, b)
+	}
+	c, err := s.SignDeterministic([]byte("hello world!"))
+	ck(t, "sign other", err)
+	an, _ := a.Nonce()
+	cn, _ := c.Nonce()
+	if bytes.Equal(an, cn) {
+		t.Fatalf("different msgs: nonce %x used twice", an)
+	}
+	p, err := s.Verify(a)
+	ck(t, "verify", err)
+	if string(p) != "hello world" {
+		t.Fatalf("have %q, want %q", p, "hello world")
+	}
+	other, err := signer.New(bytes.Repeat([]byte{1}, signer.KeySize))
+	ck(t, "new other", err)
+	d, err := other.SignDeterministic([]byte("hello world"))
+	ck(t, "sign other key", err)
+	if dn, _ := d.Nonce(); bytes.Equal(an, dn) {
+		t.Fatalf("different keys: nonce %x used twice", an)
+	}
+	w, err := signer.NewWithAEAD(s.AEAD())
+	ck(t, "new with aead", err)
+	if _, err := w.SignDeterministic([]byte("hello world")); err != signer.ErrNoKey {
+		t.Fatalf("no key: have %v, want %v", err, signer.ErrNoKey)
+	}
+}
+
+func TestClose(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	tok, err := s.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	c := s.Clone()
+	s.Close()
+	for name, op := range map[string]func() error{
+		"sign": func() error {
+			_, err := s.Sign([]byte("hello world"), nil)
+			return err
+		},
+		"verify": func() error {
+			_, err := s.Verify(tok)
+			return err
+		},
+		"sign deterministic": func() error {
+			_, err := s.SignDeterministic([]byte("hello world"))
+			return err
+		},
+		"sign batch": func() error {
+			_, err := s.SignBatch([][]byte{[]byte("hello world")})
+			return err
+		},
+		"encrypt writer": func() error {
+			_, err := s.NewEncryptWriter(ioutil.Discard)
+			return err
+		},
+		"decrypt reader": func() error {
+			_, err := s.NewDecryptReader(bytes.NewReader(nil))
+			return err
+		},
+	} {
+		if err := op(); err != signer.ErrClosed {
+			t.Fatalf("%s after close: have %v, want %v", name, err, signer.ErrClosed)
+		}
+	}
+	if _, ok := s.VerifyConstantTime(tok); ok {
+		t.Fatalf("verify constant time after close: have ok")
+	}
+	_, err = c.Verify(tok)
+	ck(t, "verify with clone", err)
+}
+
+func TestRand(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	s.Rand = repeatReader(0)
+	tok, err := s.Sign(nil, nil)
+	ck(t, "sign", err)
+	if !bytes.Equal(tok, []byte(vectorTab[0].binary)) {
+		t.Fatalf("have %s, want %s", tok, vectorTab[0].text)
+	}
+
+	s.Rand = bytes.NewReader(make([]byte, signer.NonceSize-1))
+	if tok, err := s.Sign([]byte("hello world"), nil); err != io.ErrUnexpectedEOF || tok != nil {
+		t.Fatalf("short read: have %x, %v, want nil and %v", tok, err, io.ErrUnexpectedEOF)
+	}
+	s.Rand = failReader{}
+	if tok, err := s.Sign([]byte("hello world"), nil); err != errRand || tok != nil {
+		t.Fatalf("failing source: have %x, %v, want nil and %v", tok, err, errRand)
+	}
+}
+
+var errRand = errors.New("no randomness")
+
+// failReader is a randomness source that always fails
+type failReader struct{}
+
+func (failReader) Read([]byte) (int, error) { return 0, errRand }
+
+func TestSignPadded(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	base, err := s.SignPadded(nil, nil, 1)
+	ck(t, "sign", err)
+	hdr := len(base) - 1 - s.Overhead()
+	for _, bs := range []int{1, 2, 8, 16, 255} {
+		for _, n := range []int{0, 1, bs - 1, bs, bs + 1, 2 * bs} {
+			// bytes that look like padding must survive
+			for _, b := range []byte{0, 0x80, 'a'} {
+				msg := bytes.Repeat([]byte{b}, n)
+				tok, err := s.SignPadded(msg, nil, bs)
+				ck(t, "sign", err)
+				if m := len(tok) - hdr - s.Overhead(); m%bs != 0 || m <= n {
+					t.Fatalf("block size %d, %d bytes: padded to %d", bs, n, m)
+				}
+				p, err := s.Verify(tok)
+				ck(t, "verify", err)
+				if !bytes.Equal(p, msg) || p == nil {
+					t.Fatalf("block size %d: have %x, want %x", bs, p, msg)
+				}
+			}
+		}
+	}
+	if _, err := s.SignPadded(nil, nil, 0); err != signer.ErrBlockSize {
+		t.Fatalf("zero block size: have %v, want %v", err, signer.ErrBlockSize)
+	}
+}
+
+func TestSealEnvelope(t *testing.T) {
+	s, err := signer.New(bytes.Repeat([]byte{1}, signer.KeySize))
+	ck(t, "new", err)
+	to, err := signer.New(bytes.Repeat([]byte{2}, signer.KeySize))
+	ck(t, "new", err)
+	for _, msg := range []string{"", "hello world", string(make([]byte, 10000))} {
+		env, err := s.SealEnvelope([]byte(msg))
+		ck(t, "seal", err)
+		p, err := s.OpenEnvelope(env)
+		ck(t, "open", err)
+		if string(p) != msg {
+			t.Fatalf("have %q, want %q", p, msg)
+		}
+		if _, err := to.OpenEnvelope(env); !errors.Is(err, signer.ErrUnverified) {
+			t.Fatalf("other master key: have %v, want %v", err, signer.ErrUnverified)
+		}
+
+		re, err := s.RewrapEnvelope(env, to)
+		ck(t, "rewrap", err)
+		n := s.TokenSize(signer.KeySize)
+		if !bytes.Equal(re[n:], env[n:]) {
+			t.Fatalf("rewrap changed the payload")
+		}
+		p, err = to.OpenEnvelope(re)
+		ck(t, "open rewrapped", err)
+		if string(p) != msg {
+			t.Fatalf("rewrapped: have %q, want %q", p, msg)
+		}
+		if _, err := s.OpenEnvelope(re); !errors.Is(err, signer.ErrUnverified) {
+			t.Fatalf("rewrapped, old master key: have %v, want %v", err, signer.ErrUnverified)
+		}
+
+		env[len(env)-1] ^= 1
+		if _, err := s.OpenEnvelope(env); !errors.Is(err, signer.ErrUnverified) {
+			t.Fatalf("modified payload: have %v, want %v", err, signer.ErrUnverified)
+		}
+	}
+	if _, err := s.OpenEnvelope(make(signer.Token, 10)); err != signer.ErrShort {
+		t.Fatalf("short: have %v, want %v", err, signer.ErrShort)
+	}
+}
+
+func TestDeriveSigner(t *testing.T) {
+	master := []byte("master secret")
+	a, err := signer.DeriveSigner(master, nil, []byte("tenant a"))
+	ck(t, "derive a", err)
+	b, err := signer.DeriveSigner(master, nil, []byte("tenant b"))
+	ck(t, "derive b", err)
+	again, err := signer.DeriveSigner(master, nil, []byte("tenant a"))
+	ck(t, "derive a again", err)
+	salted, err := signer.DeriveSigner(master, []byte("salt"), []byte("tenant a"))
+	ck(t, "derive salted", err)
+
+	tok, err := a.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	_, err = again.Verify(tok)
+	ck(t, "verify rebuilt", err)
+	for name, s := range map[string]*signer.Signer{"other info": b, "other salt": salted} {
+		if _, err := s.Verify(tok); !errors.Is(err, signer.ErrUnverified) {
+			t.Fatalf("%s: have %v, want %v", name, err, signer.ErrUnverified)
+		}
+	}
+	tok, err = b.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	if _, err := a.Verify(tok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("other info: have %v, want %v", err, signer.ErrUnverified)
+	}
+}
+
+func TestTokenHex(t *testing.T) {
+	tok := signer.Token(vectorTab[0].binary)
+	h := tok.Hex()
+	if h != strings.ToLower(h) || len(h) != 2*len(tok) {
+		t.Fatalf("have %q, want lowercase hex", h)
+	}
+	have, err := signer.ParseTokenHex(h)
+	ck(t, "parse", err)
+	if !bytes.Equal(have, tok) {
+		t.Fatalf("have %x, want %x", have, tok)
+	}
+	for _, s := range []string{h[1:], h + "0", h[:10] + "zz" + h[12:], "0x" + h} {
+		if _, err := signer.ParseTokenHex(s); !errors.Is(err, signer.ErrEncoding) {
+			t.Fatalf("%q: have %v, want %v", s, err, signer.ErrEncoding)
+		}
+	}
+}
+
+func TestExpiresAt(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	s.Clock = &fakeClock{time.Unix(1600000000, 0)}
+	tok, err := s.SignWithTTL([]byte("hello world"), time.Minute)
+	ck(t, "sign", err)
+	if at, ok := tok.ExpiresAt(); !ok || !at.Equal(time.Unix(1600000060, 0)) {
+		t.Fatalf("have %v, %v, want %v, true", at, ok, time.Unix(1600000060, 0))
+	}
+	tok, err = s.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	if at, ok := tok.ExpiresAt(); ok || !at.IsZero() {
+		t.Fatalf("no expiry: have %v, %v, want the zero time and false", at, ok)
+	}
+	if _, ok := signer.Token(nil).ExpiresAt(); ok {
+		t.Fatalf("empty token: have an expiry")
+	}
+}
+
+func TestSignN(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	tok, nonce, err := s.SignN([]byte("hello world"))
+	ck(t, "sign", err)
+	hdr, err := tok.Nonce()
+	ck(t, "nonce", err)
+	if !bytes.Equal(nonce, hdr) {
+		t.Fatalf("have nonce %x, header has %x", nonce, hdr)
+	}
+	again, err := s.Sign([]byte("hello world"), nonce)
+	ck(t, "sign again", err)
+	if !bytes.Equal(again, tok) {
+		t.Fatalf("signing with the returned nonce: have %x, want %x", again, tok)
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	old, err := signer.New(bytes.Repeat([]byte{1}, signer.KeySize))
+	ck(t, "new", err)
+	s, err := signer.New(bytes.Repeat([]byte{2}, signer.KeySize))
+	ck(t, "new", err)
+	tok, err := old.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	re, err := old.Rewrap(tok, s)
+	ck(t, "rewrap", err)
+	p, err := s.Verify(re)
+	ck(t, "verify", err)
+	if string(p) != "hello world" {
+		t.Fatalf("have %q, want %q", p, "hello world")
+	}
+	if _, err := old.Verify(re); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("old key: have %v, want %v", err, signer.ErrUnverified)
+	}
+	tok[len(tok)-1] ^= 1
+	if re, err := old.Rewrap(tok, s); !errors.Is(err, signer.ErrUnverified) || re != nil {
+		t.Fatalf("modified: have %x, %v, want nil and %v", re, err, signer.ErrUnverified)
+	}
+}
+
+func TestVerifyMaxAge(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	issued := time.Unix(1600000000, 0)
+	clock := &fakeClock{issued}
+	s.Clock = clock
+	tok, err := s.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	for _, tc := range []struct {
+		age  time.Duration
+		want error
+	}{
+		{0, nil},
+		{time.Hour - time.Nanosecond, nil},
+		{time.Hour, nil},
+		{time.Hour + time.Nanosecond, signer.ErrExpired},
+	} {
+		clock.t = issued.Add(tc.age)
+		p, err := s.VerifyMaxAge(tok, issued, time.Hour)
+		if err != tc.want {
+			t.Fatalf("age %v: have %v, want %v", tc.age, err, tc.want)
+		}
+		if err == nil && string(p) != "hello world" {
+			t.Fatalf("age %v: have %q, want %q", tc.age, p, "hello world")
+		}
+	}
+	clock.t = issued
+	tok[len(tok)-1] ^= 1
+	if _, err := s.VerifyMaxAge(tok, issued, time.Hour); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("modified: have %v, want %v", err, signer.ErrUnverified)
+	}
+}
+
+func TestNonceFromTime(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	now := time.Unix(1600000000, 0)
+	a, err := signer.NonceFromTime(now, []byte("host"))
+	ck(t, "nonce", err)
+	b, err := signer.NonceFromTime(now, []byte("host"))
+	ck(t, "nonce", err)
+	if len(a) != signer.NonceSize || bytes.Equal(a, b) {
+		t.Fatalf("have nonces %x and %x, want distinct %d byte nonces", a, b, signer.NonceSize)
+	}
+	tok, err := s.Sign([]byte("hello world"), a)
+	ck(t, "sign", err)
+	nonce, err := tok.Nonce()
+	ck(t, "nonce", err)
+	at, err := signer.NonceTime(nonce)
+	ck(t, "nonce time", err)
+	if !at.Equal(now) {
+		t.Fatalf("have time %v, want %v", at, now)
+	}
+	if _, err := signer.NonceFromTime(now, make([]byte, 9)); err != signer.ErrNonceLen {
+		t.Fatalf("long extra: have %v, want %v", err, signer.ErrNonceLen)
+	}
+	if _, err := signer.NonceTime(a[:8]); err != signer.ErrNonceLen {
+		t.Fatalf("short nonce: have %v, want %v", err, signer.ErrNonceLen)
+	}
+}
+
+func TestSignReader(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	s.MaxMsgSize = 100
+	msg := bytes.Repeat([]byte("a"), 100)
+	tok, err := s.SignReader(bytes.NewBuffer(msg))
+	ck(t, "sign", err)
+	var buf bytes.Buffer
+	ck(t, "verify", s.VerifyWriter(&buf, tok))
+	if !bytes.Equal(buf.Bytes(), msg) {
+		t.Fatalf("have %q, want %q", buf.Bytes(), msg)
+	}
+	if _, err := s.SignReader(bytes.NewBuffer(append(msg, 'a'))); err != signer.ErrTooLong {
+		t.Fatalf("too long: have %v, want %v", err, signer.ErrTooLong)
+	}
+	if err := s.VerifyWriter(&limitWriter{n: 10}, tok); err != errLimit {
+		t.Fatalf("failing writer: have %v, want %v", err, errLimit)
+	}
+	buf.Reset()
+	tok[len(tok)-1] ^= 1
+	if err := s.VerifyWriter(&buf, tok); !errors.Is(err, signer.ErrUnverified) || buf.Len() != 0 {
+		t.Fatalf("tampered: have %v with %d bytes written, want %v", err, buf.Len(), signer.ErrUnverified)
+	}
+}
+
+func TestArmor(t *testing.T) {
+	tok := signer.Token(vectorTab[0].binary)
+	a := tok.Armor()
+	if want := "signer:A1:" + vectorTab[0].text; a != want {
+		t.Fatalf("have %q, want %q", a, want)
+	}
+	have, err := signer.ParseArmored(a)
+	ck(t, "parse", err)
+	if !bytes.Equal(have, tok) {
+		t.Fatalf("have %x, want %x", have, tok)
+	}
+	for _, s := range []string{
+		vectorTab[0].text,
+		"Signer:A1:" + vectorTab[0].text,
+		"signer:A",
+		"signer:A2:" + vectorTab[0].text,
+		"signer:B1:" + vectorTab[0].text,
+		"signer:A1:",
+	} {
+		if _, err := signer.ParseArmored(s); err != signer.ErrArmor {
+			t.Fatalf("%q: have %v, want %v", s, err, signer.ErrArmor)
+		}
+	}
+	if _, err := signer.ParseArmored(a + "!"); !errors.Is(err, signer.ErrEncoding) {
+		t.Fatalf("bad base64: have %v, want %v", err, signer.ErrEncoding)
+	}
+}
+
+// TestStandardRFC8439 checks the AEAD of NewStandard against the test
+// vector of RFC 8439, section 2.8.2
+func TestStandardRFC8439(t *testing.T) {
+	key := make([]byte, signer.KeySize)
+	for i := range key {
+		key[i] = 0x80 + byte(i)
+	}
+	s, err := signer.NewStandard(key)
+	ck(t, "new", err)
+	if s.NonceSize() != 12 {
+		t.Fatalf("have nonce size %d, want 12", s.NonceSize())
+	}
+	unhex := func(s string) []byte {
+		p, err := hex.DecodeString(s)
+		ck(t, "hex", err)
+		return p
+	}
+	nonce := unhex("070000004041424344454647")
+	aad := unhex("50515253c0c1c2c3c4c5c6c7")
+	msg := []byte("Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it.")
+	want := unhex("" +
+		"d31a8d34648e60db7b86afbc53ef7ec2a4aded51296e08fea9e2b5a736ee62d6" +
+		"3dbea45e8ca9671282fafb69da92728b1a71de0a9e060b2905d6a5b67ecd3b36" +
+		"92ddbd7f2d778b8c9803aee328091b58fab324e4fad675945585808b4831d7bc" +
+		"3ff4def08e4b7a9de576d26586cec64b6116" +
+		"1ae10b594f09e26a7e902ecbd0600691")
+	aead := s.AEAD()
+	if have := aead.Seal(nil, nonce, msg, aad); !bytes.Equal(have, want) {
+		t.Fatalf("seal: have %x, want %x", have, want)
+	}
+	p, err := aead.Open(nil, nonce, want, aad)
+	ck(t, "open", err)
+	if !bytes.Equal(p, msg) {
+		t.Fatalf("open: have %q, want %q", p, msg)
+	}
+}
+
+func TestStreamCounterNonce(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	s.Rand, err = signer.NewCounterNonce()
+	ck(t, "counter", err)
+	if _, err := s.NewEncryptWriter(ioutil.Discard); err != signer.ErrStreamNonce {
+		t.Fatalf("counter nonce: have %v, want %v", err, signer.ErrStreamNonce)
+	}
+	_, err = s.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+}
+
+var registerShortNonce sync.Once
+
+// shortNonceSigner returns a Signer of version 'R', with 4 byte nonces
+func shortNonceSigner(t *testing.T) *signer.Signer {
+	t.Helper()
+	b, err := aes.NewCipher(vectorTab[0].key[:])
+	ck(t, "new cipher", err)
+	aead, err := cipher.NewGCMWithNonceSize(b, 4)
+	ck(t, "new gcm", err)
+	registerShortNonce.Do(func() {
+		ck(t, "register R", signer.RegisterFormat('R', signer.Format{NonceSize: 4}))
+	})
+	s, err := signer.NewWithFormat(aead, 'R')
+	ck(t, "new R", err)
+	return s
+}
+
+func TestStreamShortNonce(t *testing.T) {
+	s := shortNonceSigner(t)
+	tok, err := s.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	_, err = s.Verify(tok)
+	ck(t, "verify", err)
+	if _, err := s.NewEncryptWriter(ioutil.Discard); err != signer.ErrNonceLen {
+		t.Fatalf("encrypt: have %v, want %v", err, signer.ErrNonceLen)
+	}
+	if _, err := s.NewDecryptReader(bytes.NewReader([]byte("R0123"))); err != signer.ErrNonceLen {
+		t.Fatalf("decrypt: have %v, want %v", err, signer.ErrNonceLen)
+	}
+}
+
+func TestNewFromPassword(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	params := signer.Argon2Params{Time: 1, Memory: 64, Threads: 1}
+	a, err := signer.NewFromPassword([]byte("password"), salt, params)
+	ck(t, "new", err)
+	b, err := signer.NewFromPassword([]byte("password"), salt, params)
+	ck(t, "new again", err)
+	tok, err := a.Sign([]byte("hello world"), nil)
+	ck(t, "sign", err)
+	_, err = b.Verify(tok)
+	ck(t, "verify", err)
+	if _, err := signer.NewFromPassword([]byte("password"), salt[:15], params); err != signer.ErrSaltLen {
+		t.Fatalf("short salt: have %v, want %v", err, signer.ErrSaltLen)
+	}
+	for _, p := range []signer.Argon2Params{
+		{},
+		{Time: 0, Memory: 64, Threads: 1},
+		{Time: 1, Memory: 64, Threads: 0},
+		{Time: 1, Memory: 7, Threads: 1},
+		{Time: 1, Memory: 31, Threads: 4},
+		{Time: 1, Memory: 4<<20 + 1, Threads: 1},
+	} {
+		if _, err := signer.NewFromPassword([]byte("password"), salt, p); err != signer.ErrArgon2Params {
+			t.Fatalf("%+v: have %v, want %v", p, err, signer.ErrArgon2Params)
+		}
+	}
+}
+
+func TestSignOptsPadLimit(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	s.MaxMsgSize = 100
+	_, err = s.SignOpts(make([]byte, 99), signer.SignOptions{Pad: 100})
+	ck(t, "sign", err)
+	for _, tc := range []struct {
+		n, pad int
+		want   error
+	}{
+		{100, 100, signer.ErrTooLong},
+		{0, 101, signer.ErrTooLong},
+		{0, math.MaxInt32, signer.ErrTooLong},
+		{0, -1, signer.ErrBlockSize},
+	} {
+		if _, err := s.SignOpts(make([]byte, tc.n), signer.SignOptions{Pad: tc.pad}); err != tc.want {
+			t.Fatalf("%d bytes, pad %d: have %v, want %v", tc.n, tc.pad, err, tc.want)
+		}
+	}
+}
+
+func TestMaxMsgSize(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	big, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	big.MaxMsgSize = signer.DefaultMaxMsgSize + 1
+	msg := make([]byte, signer.DefaultMaxMsgSize+1)
+	at, over := msg[:signer.DefaultMaxMsgSize], msg
+
+	tok, err := s.Sign(at, nil)
+	ck(t, "sign at limit", err)
+	p, err := s.Verify(tok)
+	ck(t, "verify at limit", err)
+	if len(p) != len(at) {
+		t.Fatalf("verify at limit: have %d bytes, want %d", len(p), len(at))
+	}
+	_, err = s.SignTo(nil, at, nil)
+	ck(t, "sign to at limit", err)
+
+	if _, err := s.Sign(over, nil); err != signer.ErrTooLong {
+		t.Fatalf("sign over limit: have %v, want %v", err, signer.ErrTooLong)
+	}
+	if _, err := s.SignTo(nil, over, nil); err != signer.ErrTooLong {
+		t.Fatalf("sign to over limit: have %v, want %v", err, signer.ErrTooLong)
+	}
+	tok, err = big.Sign(over, nil)
+	ck(t, "sign over default limit", err)
+	if _, err := s.Verify(tok); err != signer.ErrTooLong {
+		t.Fatalf("verify over limit: have %v, want %v", err, signer.ErrTooLong)
+	}
+}
+
+func TestTokenSize(t *testing.T) {
+	key := vectorTab[0].key[:]
+	a, _ := signer.New(key)
+	b, _ := signer.NewWithKeyID(key, 1)
+	c, _ := signer.NewAESGCM(key)
+	d, _ := signer.NewStandard(key)
+	tr, _ := signer.NewTruncated(key, 64)
+	for name, s := range map[string]*signer.Signer{"A": a, "B": b, "C": c, "D": d, "truncated": tr} {
+		for _, n := range []int{0, 1, 15, 16, 17, 4096} {
+			tok, err := s.Sign(make([]byte, n), nil)
+			ck(t, "sign", err)
+			if len(tok) != s.TokenSize(n) {
+				t.Fatalf("%s, %d bytes: have %d byte token, want %d", name, n, len(tok), s.TokenSize(n))
+			}
+		}
+	}
+}
+
+func TestSignCompressed(t *testing.T) {
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	msg := bytes.Repeat([]byte("hello world "), 1000)
+	tok, err := s.SignCompressed(msg, nil)
+	ck(t, "sign", err)
+	if len(tok) >= s.TokenSize(len(msg))/10 {
+		t.Fatalf("have %d byte token for a %d byte msg", len(tok), len(msg))
+	}
+	p, err := s.Verify(tok)
+	ck(t, "verify", err)
+	if !bytes.Equal(p, msg) {
+		t.Fatalf("compressed msg did not round trip")
+	}
+	tok[len(tok)-1] ^= 1
+	if _, err := s.Verify(tok); !errors.Is(err, signer.ErrUnverified) {
+		t.Fatalf("modified: have %v, want %v", err, signer.ErrUnverified)
+	}
+
+	// an authentic token that inflates past the limit of the verifier
+	at, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	at.MaxMsgSize = len(msg)
+	small, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	small.MaxMsgSize = len(msg) - 1
+	tok, err = s.SignCompressed(msg, nil)
+	ck(t, "sign", err)
+	_, err = at.Verify(tok)
+	ck(t, "verify at limit", err)
+	if _, err := small.Verify(tok); err != signer.ErrTooLong {
+		t.Fatalf("inflates past limit: have %v, want %v", err, signer.ErrTooLong)
+	}
+}
+
+func TestNonceGuard(t *testing.T) {
+	g := signer.NewNonceGuard(3)
+	nonce := func(b byte) []byte { return bytes.Repeat([]byte{b}, signer.NonceSize) }
+	for b := byte(1); b <= 3; b++ {
+		ck(t, "use", g.Use(nonce(b)))
+	}
+	if err := g.Use(nonce(1)); err != signer.ErrNonceReused {
+		t.Fatalf("reused: have %v, want %v", err, signer.ErrNonceReused)
+	}
+	// 1 was just used again, so 2 is the least recently used
+	ck(t, "use 4", g.Use(nonce(4)))
+	ck(t, "use evicted 2", g.Use(nonce(2)))
+	for _, b := range []byte{1, 4, 2} {
+		if err := g.Use(nonce(b)); err != signer.ErrNonceReused {
+			t.Fatalf("nonce %d: have %v, want %v", b, err, signer.ErrNonceReused)
+		}
+	}
+	ck(t, "use evicted 3", g.Use(nonce(3)))
+
+	s, err := signer.New(vectorTab[0].key[:])
+	ck(t, "new", err)
+	s.Guard = signer.NewNonceGuard(16)
+	_, err = s.Sign([]byte("hello"), nonce(9))
+	ck(t, "sign", err)
+	if _, err := s.Sign([]byte("world"), nonce(9)); err != signer.ErrNonceReused {
+		t.Fatalf("sign reused: have %v, want %v", err, signer.ErrNonceReused)
+	}
+
+	for _, n := range []int{-1, 0} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("size %d: want a panic", n)
+				}
+			}()
+			signer.NewNonceGuard(n)
+		}()
+	}
+}
@@ -0,0 +1,63 @@
+// Package signertest provides test vectors for the signer wire format, so
+// that this and other implementations can check that they agree byte for
+// byte.
+package signertest
+
+import "github.com/as/signer"
+
+// Vector is a version A token and the inputs that produce it
+type Vector struct {
+	Name  string
+	Key   []byte
+	Msg   []byte
+	Nonce []byte
+	Token signer.Token
+}
+
+// TestVectors returns the test vectors. The inputs are fixed byte patterns,
+// and each Token is the expected output of signer.New(Key) and
+// Sign(Msg, Nonce), recorded as a literal so that changes to the wire
+// format are detected. The first vector is the zero vector listed in the
+// README.
+func TestVectors() []Vector {
+	return []Vector{
+		{
+			Name:  "zero",
+			Key:   make([]byte, signer.KeySize),
+			Msg:   []byte{},
+			Nonce: make([]byte, signer.NonceSize),
+			Token: token("QQAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAisgcaVtc2-73cK3jVQVd4"),
+		},
+		{
+			Name:  "byte",
+			Key:   seq(signer.KeySize, 0),
+			Msg:   []byte{'A'},
+			Nonce: seq(signer.NonceSize, 0x80),
+			Token: token("QYCBgoOEhYaHiImKi4yNjo-QkZKTlJWWlwNe5Amj4gIm1wIJ9Ac1bIb8"),
+		},
+		{
+			Name:  "blocks",
+			Key:   seq(signer.KeySize, 0x20),
+			Msg:   seq(200, 0),
+			Nonce: seq(signer.NonceSize, 0x40),
+			Token: token("QUBBQkNERUZHSElKS0xNTk9QUVJTVFVWVzogb6oiY_vYf3flM-hu7CrpRNXwwgIUVCyY2zSSItcNqgpjkFjw2ngj2N-GEsXjNMvI6VzTdY2o3wv_dHYQeyX-PncE3rlCC86SwEFBYsMx3HBLpPHfqBxc3Xa0CQDijYe5_MD_QrPygkFfS_Z8PDetZZ4_1Y3aXFarriKdxWlJO599IFZr7ex6TY511epU4GNsljvbIfLkdHHZkrwn-FQp5ExnVCbkS-vhmIdCYloN82XeYdE0d4-V806zcMQjMrPIyHibosQGQct_H4ir41R-vg0ulHZRQA"),
+		},
+	}
+}
+
+func token(s string) signer.Token {
+	t, err := signer.ParseToken(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// seq returns n bytes counting up from b
+func seq(n int, b byte) []byte {
+	p := make([]byte, n)
+	for i := range p {
+		p[i] = b + byte(i)
+	}
+	return p
+}
@@ -0,0 +1,51 @@
+package signer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+const sivPurpose = "signer deterministic nonce"
+
+// SignDeterministic signs msg with a nonce derived from msg itself, using
+// HMAC-SHA256 under a sub-key of the Signer's key. Signing the same msg
+// always yields the same token, and different messages yield different
+// nonces, so the nonce is never reused with a different msg. The token is
+// verified with Verify as usual.
+//
+// Identical tokens reveal that their messages are equal. A Signer created
+// with NewWithAEAD has no key to derive nonces from, and returns ErrNoKey.
+func (s *Signer) SignDeterministic(msg []byte) (Token, error) {
+	if s.isClosed() {
+		return nil, ErrClosed
+	}
+	if s.siv == nil {
+		return nil, ErrNoKey
+	}
+	if len(msg) > s.maxMsgSize() {
+		return nil, ErrTooLong
+	}
+	h := hmac.New(sha256.New, s.siv)
+	h.Write(msg)
+	return s.sign(msg, nil, h.Sum(nil)[:s.NonceSize()], ext{})
+}
+
+// subkey derives an independent key for the given purpose from key
+func subkey(key []byte, purpose string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(purpose))
+	return h.Sum(nil)
+}
+
+// IdempotencyToken returns a token sealing input, for use as an idempotency
+// key: equal inputs yield byte-identical tokens, from any Signer with the
+// same key and version, including in other processes, so the token can be
+// compared or used as a map key to detect duplicate requests. It is
+// SignDeterministic, and verifies with Verify to the input.
+//
+// A fixed nonce is normally unsafe, but the nonce here is derived from the
+// input itself, so a nonce is only ever repeated for the same input, which
+// reveals no more than that the inputs are equal.
+func (s *Signer) IdempotencyToken(input []byte) (Token, error) {
+	return s.SignDeterministic(input)
+}
@@ -0,0 +1,326 @@
+package signer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Streams are a sequence of sealed frames, preceded by a stream header:
+//
+//	version[1] nonce[n] framesize[4]? | frame...
+//
+// If flagExt is set in the version, the big-endian frame size follows the
+// nonce. Otherwise, frames hold up to DefaultFrameSize bytes of plaintext.
+//
+//	frame: length[4] ciphertext[...] tag[...]
+//
+// The length is big-endian and counts the ciphertext and tag. Its high bit
+// is set on the final frame, which may be empty. Each frame is sealed with
+// the stream nonce XORed with the frame's sequence number, and its
+// associated data is the sequence number, the final bit, and the stream
+// header, so frames can not be reordered, dropped, or appended after the
+// final frame, and the header can not be altered.
+const (
+	// DefaultFrameSize is the frame size of streams whose Signer has a
+	// FrameSize of zero
+	DefaultFrameSize = 64 << 10
+
+	// MinFrameSize and MaxFrameSize bound a Signer's FrameSize, and the
+	// frame sizes that a decrypt reader accepts
+	MinFrameSize = 1 << 10
+	MaxFrameSize = 16 << 20
+
+	frameFinal = 1 << 31
+)
+
+// StreamOptions configures a stream written by NewEncryptWriter
+type StreamOptions struct {
+	// FrameSize is the plaintext size of each frame, between MinFrameSize
+	// and MaxFrameSize. If zero, DefaultFrameSize is used. The size is
+	// stored in the stream, so decrypt readers need not be told it.
+	FrameSize int
+}
+
+// NewEncryptWriter returns a writer that encrypts everything written to it
+// and writes it to w as a stream of frames. The caller must Close the
+// writer to write the final frame; a stream without one fails to decrypt.
+// Close does not close w. If opts are given, the first configures the
+// stream; an invalid FrameSize returns ErrFrameSize. A Signer for a format
+// with its own Parse returns ErrVersion, and one whose nonces are shorter
+// than 8 bytes returns ErrNonceLen.
+//
+// Frame nonces count up from the stream nonce, so they would collide with
+// the nonces of later tokens if the stream nonce came from a CounterNonce.
+// A Signer whose Rand is a CounterNonce returns ErrStreamNonce.
+func (s *Signer) NewEncryptWriter(w io.Writer, opts ...StreamOptions) (io.WriteCloser, error) {
+	return s.NewEncryptWriterContext(context.Background(), w, opts...)
+}
+
+// NewEncryptWriterContext is like NewEncryptWriter, but once ctx is done,
+// Write and Close return ctx.Err() without writing any more frames. Frames
+// already written to w are left as they are, and the stream is abandoned:
+// without its final frame, it fails to decrypt with ErrTruncated.
+func (s *Signer) NewEncryptWriterContext(ctx context.Context, w io.Writer, opts ...StreamOptions) (io.WriteCloser, error) {
+	if s.isClosed() {
+		return nil, ErrClosed
+	}
+	size := 0
+	if len(opts) > 0 {
+		size = opts[0].FrameSize
+	}
+	if size == 0 {
+		size = DefaultFrameSize
+	}
+	if size < MinFrameSize || size > MaxFrameSize {
+		return nil, ErrFrameSize
+	}
+	if err := s.canStream(); err != nil {
+		return nil, err
+	}
+	if _, ok := s.Rand.(*CounterNonce); ok {
+		return nil, ErrStreamNonce
+	}
+	nonce, err := s.mknonce()
+	if err != nil {
+		return nil, err
+	}
+	hdr := append([]byte{s.version}, nonce...)
+	if size != DefaultFrameSize {
+		hdr[0] |= flagExt
+		hdr = append(hdr, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(hdr[len(hdr)-4:], uint32(size))
+	}
+	if _, err = w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		ctx:   ctx,
+		s:     s,
+		w:     w,
+		hdr:   hdr,
+		nonce: nonce,
+		buf:   make([]byte, 0, size),
+		out:   make([]byte, 4, 4+size+s.aead.Overhead()),
+	}, nil
+}
+
+// NewDecryptReader returns a reader that decrypts a stream written by an
+// encrypt writer. Plaintext is returned only after the frame containing it
+// is verified, and Read returns an error matching ErrUnverified as soon as
+// it reaches a frame that fails, without any of that frame's plaintext. If
+// the stream ends before the final frame, Read returns ErrTruncated rather
+// than io.EOF, so a cut-off stream is never mistaken for a complete one.
+// Signers that can not write streams, as documented by NewEncryptWriter,
+// return the same errors.
+func (s *Signer) NewDecryptReader(r io.Reader) (io.Reader, error) {
+	return s.NewDecryptReaderContext(context.Background(), r)
+}
+
+// NewDecryptReaderContext is like NewDecryptReader, but once ctx is done,
+// Read returns ctx.Err() instead of reading any more frames. Plaintext
+// already verified is still returned first.
+func (s *Signer) NewDecryptReaderContext(ctx context.Context, r io.Reader) (io.Reader, error) {
+	if s.isClosed() {
+		return nil, ErrClosed
+	}
+	if err := s.canStream(); err != nil {
+		return nil, err
+	}
+	hdr := make([]byte, 1+s.NonceSize())
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, truncated(err)
+	}
+	if hdr[0]&^flagExt != s.version {
+		return nil, ErrVersion
+	}
+	size := DefaultFrameSize
+	if hdr[0]&flagExt != 0 {
+		hdr = append(hdr, 0, 0, 0, 0)
+		p := hdr[len(hdr)-4:]
+		if _, err := io.ReadFull(r, p); err != nil {
+			return nil, truncated(err)
+		}
+		n := binary.BigEndian.Uint32(p)
+		if n < MinFrameSize || n > MaxFrameSize {
+			return nil, ErrFrameSize
+		}
+		size = int(n)
+	}
+	return &decryptReader{
+		ctx:   ctx,
+		s:     s,
+		r:     r,
+		hdr:   hdr,
+		nonce: hdr[1 : 1+s.NonceSize()],
+		size:  size,
+	}, nil
+}
+
+type encryptWriter struct {
+	ctx   context.Context
+	s     *Signer
+	w     io.Writer
+	hdr   []byte // stream header, authenticated with every frame
+	nonce []byte
+	seq   uint64
+	buf   []byte // pending plaintext
+	out   []byte // sealed frame
+	err   error
+}
+
+func (e *encryptWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		if e.err == nil {
+			e.err = e.ctx.Err()
+		}
+		if e.err != nil {
+			return n, e.err
+		}
+		if len(e.buf) == cap(e.buf) {
+			// the frame is only written once more data arrives, so
+			// that the last frame can be marked final on Close
+			e.err = e.flush(false)
+			continue
+		}
+		m := copy(e.buf[len(e.buf):cap(e.buf)], p)
+		e.buf = e.buf[:len(e.buf)+m]
+		n += m
+		p = p[m:]
+	}
+	return n, e.err
+}
+
+// Close writes the final frame. Subsequent calls to Write return ErrClosed.
+func (e *encryptWriter) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.err = e.flush(true); e.err == nil {
+		e.err = ErrClosed
+		return nil
+	}
+	return e.err
+}
+
+func (e *encryptWriter) flush(final bool) error {
+	if err := e.ctx.Err(); err != nil {
+		return err
+	}
+	nonce, ad := frameNonce(e.hdr, e.nonce, e.seq, final)
+	out, err := sealAEAD(e.s.aead, e.out[:4], nonce, e.buf, ad)
+	if err != nil {
+		return err
+	}
+	e.out = out
+	n := uint32(len(e.out) - 4)
+	if final {
+		n |= frameFinal
+	}
+	binary.BigEndian.PutUint32(e.out, n)
+	e.seq++
+	e.buf = e.buf[:0]
+	_, err = e.w.Write(e.out)
+	return err
+}
+
+type decryptReader struct {
+	ctx   context.Context
+	s     *Signer
+	r     io.Reader
+	hdr   []byte // stream header, authenticated with every frame
+	nonce []byte
+	size  int // frame size
+	seq   uint64
+	buf   []byte // sealed frame
+	p     []byte // verified plaintext not yet read
+	done  bool
+	err   error
+}
+
+func (d *decryptReader) Read(p []byte) (n int, err error) {
+	for len(d.p) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.err = d.next()
+	}
+	n = copy(p, d.p)
+	d.p = d.p[n:]
+	return n, nil
+}
+
+// next reads and verifies the next frame
+func (d *decryptReader) next() error {
+	if d.done {
+		return io.EOF
+	}
+	if err := d.ctx.Err(); err != nil {
+		return err
+	}
+	var hdr [4]byte
+	if _, err := io.ReadFull(d.r, hdr[:]); err != nil {
+		return truncated(err)
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	final := n&frameFinal != 0
+	n &^= frameFinal
+	if n < uint32(d.s.aead.Overhead()) || n > uint32(d.size+d.s.aead.Overhead()) {
+		return ErrUnverified
+	}
+	if cap(d.buf) < int(n) {
+		d.buf = make([]byte, n)
+	}
+	d.buf = d.buf[:n]
+	if _, err := io.ReadFull(d.r, d.buf); err != nil {
+		return truncated(err)
+	}
+	nonce, ad := frameNonce(d.hdr, d.nonce, d.seq, final)
+	p, err := openAEAD(d.s.aead, d.buf[:0], nonce, d.buf, ad)
+	if errors.Is(err, ErrInternal) {
+		return err
+	}
+	if err != nil {
+		return unverified{err}
+	}
+	d.p = p
+	d.seq++
+	d.done = final
+	return nil
+}
+
+// canStream returns ErrVersion if s's format has its own Parse, as streams
+// always use the standard header layout, and ErrNonceLen if its nonces are
+// too short to hold a frame's sequence number
+func (s *Signer) canStream() error {
+	if format(s.version).custom {
+		return ErrVersion
+	}
+	if s.NonceSize() < 8 {
+		return ErrNonceLen
+	}
+	return nil
+}
+
+// frameNonce returns the nonce and associated data for frame seq of a
+// stream with the given header and nonce
+func frameNonce(hdr, nonce []byte, seq uint64, final bool) (fnonce, ad []byte) {
+	fnonce = append([]byte(nil), nonce...)
+	tail := fnonce[len(fnonce)-8:]
+	binary.BigEndian.PutUint64(tail, binary.BigEndian.Uint64(tail)^seq)
+	ad = make([]byte, 9, 9+len(hdr))
+	binary.BigEndian.PutUint64(ad, seq)
+	if final {
+		ad[8] = 1
+	}
+	return fnonce, append(ad, hdr...)
+}
+
+// truncated converts an early end of stream into ErrTruncated
+func truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrTruncated
+	}
+	return err
+}
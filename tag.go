@@ -0,0 +1,37 @@
+package signer
+
+// Tag returns a token authenticating msg without encrypting it. The token
+// holds only the header and the AEAD's tag, computed with msg as associated
+// data, so its size does not depend on msg. The msg must be stored or sent
+// separately, and it is not kept secret. The header marks the token as a
+// tag, so it never verifies as a token with an empty msg, such as one from
+// SignWithAAD, SignFor, or SetSignedCookie, and those never verify as tags.
+func (s *Signer) Tag(msg, nonce []byte) (t Token, err error) {
+	if nonce, err = s.nonce(nonce); err != nil {
+		return nil, err
+	}
+	return s.sign(nil, msg, nonce, ext{flags: flagTag})
+}
+
+// VerifyTag verifies that msg is unmodified with respect to a token
+// created by Tag. If either was modified, the error matches ErrUnverified.
+func (s *Signer) VerifyTag(t Token, msg []byte) error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+	h, err := s.parseTag(t)
+	if err != nil {
+		return s.fail(err)
+	}
+	switch n := len(t) - h.n; {
+	case n < s.Overhead():
+		return s.fail(ErrTruncatedTag)
+	case n > s.Overhead():
+		// a tag never has a msg
+		return s.fail(ErrUnverified)
+	}
+	if _, err = s.unseal(nil, h, t[:h.n], t[h.n:], msg); err != nil {
+		return s.fail(err)
+	}
+	return nil
+}
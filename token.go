@@ -1,33 +1,225 @@
 package signer
 
-import "encoding/base64"
+import (
+	"crypto/subtle"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
 
 var (
 	codec = base64.RawURLEncoding
+
+	// parseCodecs are the variants ParseToken accepts, codec first
+	parseCodecs = []*base64.Encoding{
+		base64.RawURLEncoding,
+		base64.URLEncoding,
+		base64.RawStdEncoding,
+		base64.StdEncoding,
+	}
+
+	_ encoding.TextMarshaler     = Token(nil)
+	_ encoding.TextUnmarshaler   = (*Token)(nil)
+	_ encoding.BinaryMarshaler   = Token(nil)
+	_ encoding.BinaryUnmarshaler = (*Token)(nil)
 )
 
 // Token is a byte slice that knows how to marshal and unmarshal itself in base64
 type Token []byte
 
+// ParseToken decodes a url-safe base64-encoded token, as returned by Encode.
+// Standard base64, and either alphabet with padding, are also accepted,
+// but the input must be entirely in one of those variants: mixing the
+// alphabets, or padding incorrectly, returns an error wrapping ErrEncoding,
+// as does any other malformed input.
+func ParseToken(s string) (Token, error) {
+	var first error
+	for _, enc := range parseCodecs {
+		t, err := enc.DecodeString(s)
+		if err == nil {
+			return t, nil
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return nil, encodingError(first)
+}
+
+// ParseTokenHex decodes a hex-encoded token, as returned by Hex. Malformed
+// input, including an odd number of digits, returns an error wrapping
+// ErrEncoding.
+func ParseTokenHex(s string) (Token, error) {
+	t, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, encodingError(err)
+	}
+	return t, nil
+}
+
+// Hex returns the token in lowercase hex
+func (t Token) Hex() string {
+	return hex.EncodeToString(t)
+}
+
+// Armor returns the base64 url-safe encoded token with a prefix naming the
+// format, the token's version, and the armor revision (1), e.g.
+// "signer:A1:QQAAAA...". The prefix makes tokens recognizable in config
+// files or emails, and lets ParseArmored detect some corruption.
+func (t Token) Armor() string {
+	v, err := t.Version()
+	if err != nil {
+		v = '?'
+	}
+	return armorPrefix + string(rune(v)) + "1:" + t.Encode()
+}
+
+// ParseArmored decodes a token encoded by Armor. If the prefix is missing
+// or does not match the token's version, it returns ErrArmor. Malformed
+// base64 returns an error wrapping ErrEncoding.
+func ParseArmored(s string) (Token, error) {
+	if !strings.HasPrefix(s, armorPrefix) {
+		return nil, ErrArmor
+	}
+	s = s[len(armorPrefix):]
+	if len(s) < 3 || s[1:3] != "1:" {
+		return nil, ErrArmor
+	}
+	t, err := ParseToken(s[3:])
+	if err != nil {
+		return nil, err
+	}
+	if v, err := t.Version(); err != nil || v != s[0] {
+		return nil, ErrArmor
+	}
+	return t, nil
+}
+
+const armorPrefix = "signer:"
+
+// encodingError wraps an error from a decoder so that it matches
+// ErrEncoding
+func encodingError(err error) error {
+	return fmt.Errorf("%w: %v", ErrEncoding, err)
+}
+
+// Encode returns a url-safe base64-encoded token without padding, suitable
+// for use in URLs, HTTP headers, and cookies
+func (t Token) Encode() string {
+	return codec.EncodeToString(t)
+}
+
 // String returns a url-safe base64-encoded token
 func (t Token) String() string {
 	s, _ := t.MarshalText()
 	return string(s)
 }
 
-// MarshalText returns a url-safe base64-encoded token as a byte slice
+// MarshalText returns a url-safe base64-encoded token as a byte slice. A nil
+// or empty token encodes as an empty string.
 func (t Token) MarshalText() ([]byte, error) {
 	dst := make([]byte, codec.EncodedLen(len(t)))
 	codec.Encode(dst, t)
 	return dst, nil
 }
 
-// UnmarshalText decodes a url-safe base64-encoded token
+// UnmarshalText decodes a url-safe base64-encoded token, reusing the
+// token's storage when possible. An empty input yields a nil token.
 func (t *Token) UnmarshalText(p []byte) error {
+	if len(p) == 0 {
+		*t = nil
+		return nil
+	}
 	n := codec.DecodedLen(len(p))
-	if len(*t) < n {
-		*t = append(*t, make([]byte, n-len(*t))...)
+	if cap(*t) < n {
+		*t = make(Token, n)
+	}
+	n, err := codec.Decode((*t)[:n], p)
+	if err != nil {
+		return encodingError(err)
+	}
+	*t = (*t)[:n]
+	return nil
+}
+
+// MarshalBinary returns a copy of the token
+func (t Token) MarshalBinary() ([]byte, error) {
+	return append([]byte(nil), t...), nil
+}
+
+// UnmarshalBinary stores a copy of p in the token. An empty input yields a
+// nil token, and an input shorter than the header of its version returns
+// ErrShort. The rest of the token is not checked.
+func (t *Token) UnmarshalBinary(p []byte) error {
+	if len(p) == 0 {
+		*t = nil
+		return nil
+	}
+	if _, err := Token(p).parse(); err == ErrShort {
+		return ErrShort
+	}
+	*t = append((*t)[:0], p...)
+	return nil
+}
+
+// Version returns the token's version, or ErrVersion if it is unknown. Flag
+// bits in the version byte are cleared.
+// The header is read as-is and is not authenticated; a valid version does
+// not imply the token is authentic.
+func (t Token) Version() (byte, error) {
+	h, err := t.parse()
+	if err != nil {
+		return 0, err
+	}
+	return h.version, nil
+}
+
+// Valid reports whether t is structurally plausible: it has a known
+// version, a complete header, and room for a tag after it. This is NOT a
+// verification, and is only useful for cheaply rejecting malformed tokens;
+// use a Signer's Verify to authenticate them. ValidateLayout reports why t
+// is not valid.
+func (t Token) Valid() bool {
+	return validateLayout(t) == nil
+}
+
+// Nonce returns a copy of the token's nonce. Like Version, this reads
+// untrusted data and does not imply authenticity.
+func (t Token) Nonce() ([]byte, error) {
+	h, err := t.parse()
+	if err != nil {
+		return nil, err
 	}
-	_, err := codec.Decode(*t, p)
-	return err
+	return append([]byte(nil), h.nonce...), nil
+}
+
+// Ciphertext returns the token's sealed region, the ciphertext and tag
+// following the header, without copying it: modifying the returned slice
+// modifies the token. Like Version, this reads untrusted data and does not
+// imply authenticity. A token that is not Valid returns the error from
+// ValidateLayout, such as ErrTruncatedTag for one too short to hold a tag.
+func (t Token) Ciphertext() ([]byte, error) {
+	h, err := t.parse()
+	if err != nil {
+		return nil, err
+	}
+	if err = validateLayout(t); err != nil {
+		return nil, err
+	}
+	return t[h.n:], nil
+}
+
+// Equal reports whether t and u are identical, in time independent of their
+// contents. Tokens of different lengths are unequal, and only the lengths
+// are revealed by timing.
+//
+// Use Equal when one token is attacker-controlled and the other is secret,
+// such as an idempotency key or cached token looked up by a client's value:
+// a bytes.Equal that returns early leaks how long a prefix matched. Tokens
+// are authenticated, so bytes.Equal is fine for comparing tokens that have
+// already passed Verify, or when neither side is secret.
+func (t Token) Equal(u Token) bool {
+	return subtle.ConstantTimeCompare(t, u) == 1
 }
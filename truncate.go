@@ -0,0 +1,73 @@
+package signer
+
+import (
+	"crypto/subtle"
+	"errors"
+)
+
+// MinTagBits is the smallest tag accepted by NewTruncated
+const MinTagBits = 64
+
+var errTagMismatch = errors.New("truncated tag mismatch")
+
+// NewTruncated is like New, but the Signer truncates each token's 128-bit
+// Poly1305 tag to tagBits, which must be a multiple of 8 between
+// MinTagBits and 128. The tag length is recorded in the header, and Verify
+// compares the retained tag bytes in constant time. Tokens with a 128-bit
+// tag are not truncated at all, and have no tag length in the header.
+//
+// Truncation trades authentication strength for a shorter token: a forger
+// who can submit many guesses succeeds with probability about 2^-tagBits
+// per guess, rather than 2^-128. At 64 bits, an online attacker must still
+// submit on the order of 2^63 forgeries, but offline or high-volume
+// settings may need more. Use the full tag unless every byte counts, such
+// as in QR codes or SMS.
+func NewTruncated(key []byte, tagBits int) (*Signer, error) {
+	if tagBits%8 != 0 || tagBits < MinTagBits || tagBits > 128 {
+		return nil, ErrTagSize
+	}
+	s, err := New(key)
+	if err != nil {
+		return nil, err
+	}
+	if tagBits < 128 {
+		s.tagLen = tagBits / 8
+	}
+	return s, nil
+}
+
+// truncate removes the end of the tag from the output of Seal
+func (s *Signer) truncate(sealed []byte) []byte {
+	if s.tagLen == 0 {
+		return sealed
+	}
+	return sealed[:len(sealed)-s.aead.Overhead()+s.tagLen]
+}
+
+// openTruncated is like the AEAD's Open, for a ciphertext with a truncated
+// tag. The AEAD can not verify a partial tag, so the plaintext is recovered
+// with the AEAD's keystream and sealed again to recompute the full tag.
+// This relies on the ciphertext being the plaintext XOR a keystream that
+// depends only on the key and nonce, as it is for ChaCha20-Poly1305.
+func (s *Signer) openTruncated(dst, nonce, ae, ad []byte) ([]byte, error) {
+	n := len(ae) - s.tagLen
+	ct, tag := ae[:n], ae[n:]
+	p, err := sealAEAD(s.aead, nil, nonce, make([]byte, n), ad)
+	if err != nil {
+		return nil, err
+	}
+	p = p[:n]
+	for i := range p {
+		p[i] ^= ct[i]
+	}
+	full, err := sealAEAD(s.aead, nil, nonce, p, ad)
+	if err != nil {
+		zero(p)
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(full[n:n+s.tagLen], tag) != 1 {
+		zero(p)
+		return nil, errTagMismatch
+	}
+	return append(dst, p...), nil
+}
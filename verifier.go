@@ -0,0 +1,28 @@
+package signer
+
+// NewVerifier returns a Verifier configured with key, if and only if
+// len(key) == 32.
+func NewVerifier(key []byte) (*Verifier, error) {
+	s, err := New(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{s: s}, nil
+}
+
+// Verifier can Verify Tokens, but not Sign them. Services that only consume
+// tokens should hold a Verifier rather than a Signer, to make the contract
+// explicit in the API.
+//
+// The key is still symmetric: anyone with the key can sign tokens, so a
+// Verifier restricts capability only at the API level. Separating the
+// ability to verify from the ability to sign at the key level would need
+// an asymmetric scheme, which Signer does not provide.
+type Verifier struct {
+	s *Signer
+}
+
+// Verify verifies and decrypts the token. See Signer.Verify.
+func (v *Verifier) Verify(t Token) (msg []byte, err error) {
+	return v.s.Verify(t)
+}
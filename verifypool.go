@@ -0,0 +1,31 @@
+package signer
+
+import "sync"
+
+// maxPooledSize is the capacity of the largest buffer VerifyPooled keeps
+// for reuse; larger buffers are left to the garbage collector
+const maxPooledSize = 64 << 10
+
+var verifyPool = sync.Pool{
+	New: func() interface{} { return new([]byte) },
+}
+
+// VerifyPooled is like Verify, but decrypts the msg into a buffer drawn
+// from a pool shared by all Signers, avoiding an allocation per call on busy
+// verify paths. The caller must call release exactly once, when it is done
+// with the msg. After release, the msg must not be used, or retained in any
+// way: its storage is handed to a later VerifyPooled call. On error, release
+// is nil.
+func (s *Signer) VerifyPooled(t Token) (msg []byte, release func(), err error) {
+	buf := verifyPool.Get().(*[]byte)
+	if msg, err = s.open((*buf)[:0], t, nil); err != nil {
+		verifyPool.Put(buf)
+		return nil, nil, err
+	}
+	return msg, func() {
+		if cap(msg) <= maxPooledSize {
+			*buf = msg[:0]
+			verifyPool.Put(buf)
+		}
+	}, nil
+}